@@ -0,0 +1,340 @@
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the cache commands of --t4c-cache
+const (
+	cacheList  = "list"
+	cachePrune = "prune"
+	cacheClear = "clear"
+
+	defaultPruneAge = 30 * 24 * time.Hour
+)
+
+// a cached classpath, of all the files sharing its key
+type cacheEntry struct {
+	key      string
+	files    []string
+	size     int64
+	created  time.Time
+	lastUsed time.Time
+	meta     t4cSettings
+}
+
+// Record what a cached classpath is for, in a flat edn map next to it,
+// and when it was last used, as the modification time of that file.
+func touchCacheMeta(config t4cConfig, options *allOpts, stale bool) {
+	if stale || !fileExists(config.metaFile) {
+		dir, _ := os.Getwd()
+		meta := "{:dir " + strconv.Quote(dir) + "\n :aliases " + strconv.Quote(aliasesOf(options))
+		if len(options.Clj.ToolName) > 0 {
+			meta += "\n :tool " + strconv.Quote(options.Clj.ToolName)
+		}
+		meta += "}\n"
+		os.WriteFile(config.metaFile, []byte(meta), 0644)
+		return
+	}
+	now := time.Now()
+	os.Chtimes(config.metaFile, now, now)
+}
+
+// the aliases of the options, as given in the command line
+func aliasesOf(options *allOpts) string {
+	aliases := []string{}
+	for _, a := range options.Clj.ReplAliases {
+		aliases = append(aliases, "-A"+a)
+	}
+	for _, a := range []struct{ flag, aliases string }{
+		{"-M", options.Clj.MainAliases},
+		{"-X", options.Clj.ExecAliases},
+		{"-T", options.Clj.ToolAliases},
+	} {
+		if len(a.aliases) > 0 {
+			aliases = append(aliases, a.flag+a.aliases)
+		}
+	}
+	return join(aliases, " ")
+}
+
+// Cache keys are sha256 hex digests. The crc32 numbered keys of older
+// versions are left for the official clojure tools sharing the cache.
+func isCacheKey(key string) bool {
+	return len(key) == 64 && strings.Trim(key, "0123456789abcdef") == ""
+}
+
+// the cached classpaths in cacheDir, the most recently used first
+func cacheEntries(cacheDir string) ([]cacheEntry, error) {
+	files, err := os.ReadDir(cacheDir)
+	if os.IsNotExist(err) {
+		return []cacheEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := map[string]*cacheEntry{}
+	for _, f := range files {
+		key, ext, found := strings.Cut(f.Name(), ".")
+		if f.IsDir() || !found || !isCacheKey(key) || ext == "lock" {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+
+		entry, found := byKey[key]
+		if !found {
+			entry = &cacheEntry{key: key, created: info.ModTime(), meta: t4cSettings{}}
+			byKey[key] = entry
+		}
+		file := path.Join(cacheDir, f.Name())
+		entry.files = append(entry.files, file)
+		entry.size += info.Size()
+		if info.ModTime().Before(entry.created) {
+			entry.created = info.ModTime()
+		}
+		if info.ModTime().After(entry.lastUsed) {
+			entry.lastUsed = info.ModTime()
+		}
+		if strings.HasSuffix(f.Name(), ".meta") {
+			meta, err := readSettings(file)
+			if err == nil {
+				entry.meta = meta
+			}
+		}
+	}
+
+	entries := []cacheEntry{}
+	for _, entry := range byKey {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastUsed.After(entries[j].lastUsed)
+	})
+	return entries, nil
+}
+
+// the project cache, when there is one, the user cache, and the
+// -Scache-dir one, when given
+func cacheDirs(cliCacheDir string) ([]string, error) {
+	dirs := []string{}
+	if dirExists(".cpcache") {
+		dirs = append(dirs, ".cpcache")
+	}
+	configDir, err := getConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	userCache, err := getCljCacheDir(configDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range []string{userCache, cliCacheDir} {
+		if len(dir) > 0 && !containsDir(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
+
+func containsDir(dirs []string, dir string) bool {
+	for _, d := range dirs {
+		if sameDir(d, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameDir(dir1 string, dir2 string) bool {
+	abs1, err1 := filepath.Abs(dir1)
+	abs2, err2 := filepath.Abs(dir2)
+	return err1 == nil && err2 == nil && abs1 == abs2
+}
+
+// run a --t4c-cache command on the project, the user, and the -Scache-dir cache
+func runCacheCommand(command string, olderThan time.Duration, cliCacheDir string) error {
+	dirs, err := cacheDirs(cliCacheDir)
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		switch command {
+		case cacheList:
+			err = listCache(dir, time.Now())
+		case cachePrune:
+			err = pruneCache(dir, olderThan, time.Now())
+		case cacheClear:
+			err = pruneCache(dir, 0, time.Now())
+		default:
+			err = errors.New("unknown cache command: " + command)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func listCache(cacheDir string, now time.Time) error {
+	entries, err := cacheEntries(cacheDir)
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, entry := range entries {
+		size += entry.size
+	}
+	fmt.Println(cacheDir + " (" + strconv.Itoa(len(entries)) + " classpaths, " + formatBytes(size) + ")")
+
+	for _, entry := range entries {
+		key := entry.key
+		if len(key) > 12 {
+			key = key[:12]
+		}
+		aliases := entry.meta.value(":aliases")
+		if tool := entry.meta.value(":tool"); tool != "" {
+			aliases = strings.TrimSpace("tool " + tool + " " + aliases)
+		}
+		if aliases == "" {
+			aliases = "-"
+		}
+		fmt.Printf("  %-12s  %-20s  age %-4s  %10s  used %s ago  %s\n", key, aliases,
+			formatAge(now.Sub(entry.created)), formatBytes(entry.size),
+			formatAge(now.Sub(entry.lastUsed)), entry.meta.value(":dir"))
+	}
+	return nil
+}
+
+// remove the cached classpaths not used for longer than olderThan
+func pruneCache(cacheDir string, olderThan time.Duration, now time.Time) error {
+	entries, err := cacheEntries(cacheDir)
+	if err != nil {
+		return err
+	}
+	removed := 0
+	var freed int64
+	for _, entry := range entries {
+		if olderThan > 0 && now.Sub(entry.lastUsed) < olderThan {
+			continue
+		}
+		done, err := removeCacheEntry(cacheDir, entry)
+		if err != nil {
+			return err
+		}
+		if !done {
+			continue
+		}
+		removed++
+		freed += entry.size
+	}
+	err = removeOrphanLocks(cacheDir)
+	if err != nil {
+		return err
+	}
+	fmt.Println(cacheDir + ": removed " + strconv.Itoa(removed) + " classpaths, freed " + formatBytes(freed))
+	return nil
+}
+
+// remove a cached classpath, when not locked by a process refreshing it
+func removeCacheEntry(cacheDir string, entry cacheEntry) (bool, error) {
+	lock, err := tryAcquireLock(path.Join(cacheDir, entry.key+".lock"))
+	if err != nil {
+		return false, err
+	}
+	if lock == nil {
+		return false, nil
+	}
+
+	for _, file := range entry.files {
+		err = os.Remove(file)
+		if err != nil && !os.IsNotExist(err) {
+			lock.release()
+			return false, err
+		}
+	}
+	return true, lock.remove()
+}
+
+// remove the lock files of keys with no cached classpath, when not locked
+// by a process computing it
+func removeOrphanLocks(cacheDir string) error {
+	locks, err := filepath.Glob(path.Join(cacheDir, "*.lock"))
+	if err != nil {
+		return err
+	}
+	for _, lockFile := range locks {
+		key := strings.TrimSuffix(path.Base(lockFile), ".lock")
+		others, err := filepath.Glob(path.Join(cacheDir, key+".*"))
+		if err != nil {
+			return err
+		}
+		if !isCacheKey(key) || len(others) > 1 {
+			continue
+		}
+		lock, err := tryAcquireLock(lockFile)
+		if err != nil {
+			return err
+		}
+		if lock != nil {
+			err = lock.remove()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// a duration like 30d, 12h or 90m
+func parseAge(age string) (time.Duration, error) {
+	if strings.HasSuffix(age, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(age, "d"))
+		if err != nil || days < 0 {
+			return 0, errors.New("invalid age: " + age)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(age)
+	if err != nil || d < 0 {
+		return 0, errors.New("invalid age: " + age)
+	}
+	return d, nil
+}
+
+// a short, rounded down, duration, like 45s, 12m, 5h or 3d
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return strconv.Itoa(int(d.Seconds())) + "s"
+	case d < time.Hour:
+		return strconv.Itoa(int(d.Minutes())) + "m"
+	case d < 24*time.Hour:
+		return strconv.Itoa(int(d.Hours())) + "h"
+	default:
+		return strconv.Itoa(int(d.Hours()/24)) + "d"
+	}
+}
@@ -0,0 +1,183 @@
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsCacheKey(t *testing.T) {
+	testItems := map[string]bool{
+		strings.Repeat("a1", 32): true,
+		"2234442315":             false,
+		strings.Repeat("a1", 16): false,
+		strings.Repeat("A1", 32): false,
+		"deps":                   false,
+		"":                       false,
+	}
+
+	for key, expected := range testItems {
+		if isCacheKey(key) != expected {
+			t.Errorf("isCacheKey of %v failed, expected %v", key, expected)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	testItems := map[string]time.Duration{
+		"30d": 30 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+		"90m": 90 * time.Minute,
+		"0d":  0,
+	}
+
+	for age, expected := range testItems {
+		res, err := parseAge(age)
+		if err != nil || res != expected {
+			t.Errorf("parseAge of %v failed, expected %v, got %v, error %v", age, expected, res, err)
+		}
+	}
+
+	for _, age := range []string{"30", "d", "-1d", "month"} {
+		_, err := parseAge(age)
+		if err == nil {
+			t.Errorf("expected to get an error for invalid age %v", age)
+		}
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	testItems := map[time.Duration]string{
+		45 * time.Second:     "45s",
+		12 * time.Minute:     "12m",
+		5*time.Hour + 59:     "5h",
+		73 * time.Hour:       "3d",
+		-1 * time.Nanosecond: "0s",
+	}
+
+	for d, expected := range testItems {
+		if formatAge(d) != expected {
+			t.Errorf("formatAge of %v failed, expected %v, got %v", d, expected, formatAge(d))
+		}
+	}
+}
+
+func TestAliasesOf(t *testing.T) {
+	options := allOpts{
+		Clj: cljOpts{
+			ReplAliases: []string{":dev", ":test"},
+			MainAliases: ":run",
+		},
+	}
+	expected := "-A:dev -A:test -M:run"
+	if aliasesOf(&options) != expected {
+		t.Errorf("wrong aliases, expected %v, got %v", expected, aliasesOf(&options))
+	}
+}
+
+func TestCacheMaintenance(t *testing.T) {
+	cacheDir := "test-cpcache"
+	defer os.RemoveAll(cacheDir)
+	os.MkdirAll(cacheDir, os.ModePerm)
+
+	now := time.Now()
+	oldKey := strings.Repeat("0a", 32)
+	newKey := strings.Repeat("0b", 32)
+	legacyKey := "2234442315"
+
+	// an old, a recently used, and a legacy cached classpath, left for the
+	// official clojure tools
+	options := allOpts{Clj: cljOpts{MainAliases: ":dev"}}
+	for _, key := range []string{oldKey, newKey} {
+		config := t4cConfig{}
+		buildCmdConfigs(&config, cacheDir, key)
+		os.WriteFile(config.cpFile, []byte("src"), 0644)
+		os.WriteFile(config.jvmFile, []byte{}, 0644)
+		touchCacheMeta(config, &options, true)
+	}
+	os.WriteFile(path.Join(cacheDir, legacyKey+".cp"), []byte("src"), 0644)
+	os.WriteFile(path.Join(cacheDir, "not-a-key.txt"), []byte{}, 0644)
+
+	old := now.Add(-40 * 24 * time.Hour)
+	for _, ext := range []string{".cp", ".jvm", ".meta"} {
+		os.Chtimes(path.Join(cacheDir, oldKey+ext), old, old)
+	}
+	os.Chtimes(path.Join(cacheDir, legacyKey+".cp"), old, old)
+
+	entries, err := cacheEntries(cacheDir)
+	if err != nil {
+		t.Errorf("failed to get cache entries: %v", err)
+	}
+	if len(entries) != 2 || entries[0].key != newKey {
+		t.Errorf("wrong cache entries, got %+v", entries)
+		t.FailNow()
+	}
+	if len(entries[0].files) != 3 || entries[0].meta.value(":aliases") != "-M:dev" {
+		t.Errorf("wrong cache entry, got %+v", entries[0])
+	}
+
+	err = listCache(cacheDir, now)
+	if err != nil {
+		t.Errorf("failed to list cache: %v", err)
+	}
+
+	// the locked ones, being refreshed, are kept
+	lock, err := acquireLock(path.Join(cacheDir, newKey+".lock"), time.Second, "")
+	if err != nil {
+		t.Errorf("failed to acquire lock: %v", err)
+		t.FailNow()
+	}
+	err = pruneCache(cacheDir, 0, now)
+	lock.release()
+	if err != nil {
+		t.Errorf("failed to prune cache: %v", err)
+	}
+	entries, _ = cacheEntries(cacheDir)
+	if len(entries) != 1 || entries[0].key != newKey {
+		t.Errorf("wrong cache entries after locked prune, got %+v", entries)
+	}
+	os.WriteFile(path.Join(cacheDir, oldKey+".cp"), []byte("src"), 0644)
+	os.Chtimes(path.Join(cacheDir, oldKey+".cp"), old, old)
+
+	// prune the ones not used for 30 days
+	err = pruneCache(cacheDir, 30*24*time.Hour, now)
+	if err != nil {
+		t.Errorf("failed to prune cache: %v", err)
+	}
+	entries, _ = cacheEntries(cacheDir)
+	if len(entries) != 1 || entries[0].key != newKey {
+		t.Errorf("wrong cache entries after prune, got %+v", entries)
+	}
+
+	// clear all, along with the lock files
+	orphanLock := path.Join(cacheDir, strings.Repeat("0c", 32)+".lock")
+	os.WriteFile(orphanLock, []byte{}, 0644)
+	err = pruneCache(cacheDir, 0, now)
+	if err != nil {
+		t.Errorf("failed to clear cache: %v", err)
+	}
+	entries, _ = cacheEntries(cacheDir)
+	if len(entries) != 0 || !fileExists(path.Join(cacheDir, "not-a-key.txt")) ||
+		!fileExists(path.Join(cacheDir, legacyKey+".cp")) {
+		t.Errorf("wrong cache entries after clear, got %+v", entries)
+	}
+	locks, _ := filepath.Glob(path.Join(cacheDir, "*.lock"))
+	if len(locks) != 0 {
+		t.Errorf("expected no lock files after clear, got %v", locks)
+	}
+}
@@ -0,0 +1,193 @@
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// longer classpaths are passed to java in an argument file,
+// to bypass the command line length limits
+const maxInlineClassPath = 2048
+
+// a classpath, either inline, or in a java argument file (@file)
+type classPath struct {
+	value   string
+	argFile string
+}
+
+func classPathOf(cp string) classPath {
+	if strings.HasPrefix(cp, "@") {
+		return classPath{argFile: strings.TrimPrefix(cp, "@")}
+	}
+	return classPath{value: cp}
+}
+
+// the classpath cached in cpFile, in an argument file when too long
+func readClassPath(cpFile string) (classPath, error) {
+	b, err := os.ReadFile(cpFile)
+	if err != nil {
+		return classPath{}, err
+	}
+	if len(b) > maxInlineClassPath {
+		return classPath{argFile: cpFile}, nil
+	}
+	return classPath{value: string(b)}, nil
+}
+
+// the classpath as a java -classpath argument
+func (cp classPath) String() string {
+	if cp.argFile != "" {
+		return "@" + cp.argFile
+	}
+	return cp.value
+}
+
+// the classpath value, read from its argument file when needed,
+// where it may be quoted
+func (cp classPath) text() (string, error) {
+	if cp.argFile == "" {
+		return cp.value, nil
+	}
+	b, err := os.ReadFile(cp.argFile)
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(string(b))
+	if len(text) >= 2 && strings.HasPrefix(text, "\"") && strings.HasSuffix(text, "\"") {
+		text = strings.ReplaceAll(text[1:len(text)-1], "\\\\", "\\")
+	}
+	return text, nil
+}
+
+func (cp classPath) entries() ([]string, error) {
+	text, err := cp.text()
+	if err != nil {
+		return nil, err
+	}
+	entries := []string{}
+	for _, entry := range strings.Split(text, string(os.PathListSeparator)) {
+		entry = strings.TrimSpace(entry)
+		if entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// The jars, and the absolute directories (of git or local deps), of the
+// classpath that no longer exist. Relative directories are project paths,
+// like a resources directory, that do not have to exist. A deps directory
+// may not exist either, so it is missing only when its dep root is removed.
+func (cp classPath) missingEntries(stats *fileStats, roots []string) ([]string, error) {
+	entries, err := cp.entries()
+	if err != nil {
+		return nil, err
+	}
+	libs := filepath.Join(gitlibsDir(), "libs")
+	isMissing := checkAll(entries, func(entry string) bool {
+		if strings.HasSuffix(entry, ".jar") {
+			return !stats.fileExists(entry)
+		}
+		if filepath.IsAbs(entry) {
+			root := depRootOf(entry, libs, roots)
+			return root != "" && !stats.dirExists(root)
+		}
+		return false
+	})
+	missing := []string{}
+	for i, entry := range entries {
+		if isMissing[i] {
+			missing = append(missing, entry)
+		}
+	}
+	return missing, nil
+}
+
+// The roots of the local and git deps of a classpath, the directories
+// of the deps manifests listed by clojure tools.
+func depRoots(manifestFile string) ([]string, error) {
+	roots := []string{}
+	if !fileExists(manifestFile) {
+		return roots, nil
+	}
+	manifests, err := readNonEmptyLines(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, manifest := range manifests {
+		roots = append(roots, filepath.Dir(manifest))
+	}
+	return roots, nil
+}
+
+// The dep root of a classpath directory: its checkout in the gitlibs libs
+// directory, or else the longest of the (clean) roots containing it, empty
+// when not the directory of a dep.
+func depRootOf(entry string, libs string, roots []string) string {
+	entry = filepath.Clean(entry)
+	if isPathWithin(entry, libs) {
+		// a checkout is at libs/<lib group>/<lib name>/<rev>
+		parts := strings.SplitN(entry[len(libs)+1:], string(filepath.Separator), 4)
+		if len(parts) >= 3 {
+			return filepath.Join(libs, parts[0], parts[1], parts[2])
+		}
+	}
+	root := ""
+	for _, r := range roots {
+		if len(r) > len(root) && (entry == r || isPathWithin(entry, r)) {
+			root = r
+		}
+	}
+	return root
+}
+
+// a path is within a directory, below it
+func isPathWithin(name string, dir string) bool {
+	return len(name) > len(dir) && strings.HasPrefix(name, dir) && name[len(dir)] == filepath.Separator
+}
+
+// the gitlibs directory of clojure tools, set by GITLIBS, or ~/.gitlibs
+func gitlibsDir() string {
+	env, found := os.LookupEnv("GITLIBS")
+	if found && env != "" {
+		return env
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".gitlibs")
+}
+
+// The classpath with one more entry. An argument file classpath is copied
+// to a file.exec one, along with the entry, unless already up to date.
+func (cp classPath) withEntry(entry string) classPath {
+	if cp.argFile == "" {
+		return classPath{value: cp.value + string(os.PathListSeparator) + entry}
+	}
+
+	execFile := cp.argFile + ".exec"
+	newer, _ := checkIsNewerFile(execFile, cp.argFile)
+	if !newer {
+		b, err := os.ReadFile(cp.argFile)
+		if err == nil {
+			// a trailing new line would split the argument
+			text := strings.TrimRight(string(b), " \t\r\n")
+			os.WriteFile(execFile, []byte(text+string(os.PathListSeparator)+entry), 0644)
+		}
+	}
+	return classPath{argFile: execFile}
+}
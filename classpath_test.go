@@ -0,0 +1,155 @@
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClassPathOf(t *testing.T) {
+	cp := classPathOf("@test.cp")
+	if cp.argFile != "test.cp" || cp.String() != "@test.cp" {
+		t.Errorf("wrong argument file classpath, got %+v", cp)
+	}
+	cp = classPathOf("src:test")
+	if cp.value != "src:test" || cp.String() != "src:test" {
+		t.Errorf("wrong inline classpath, got %+v", cp)
+	}
+}
+
+func TestReadClassPath(t *testing.T) {
+	cpFile := "test.cp"
+	defer os.Remove(cpFile)
+
+	os.WriteFile(cpFile, []byte("src"), 0644)
+	cp, err := readClassPath(cpFile)
+	if err != nil || cp.String() != "src" {
+		t.Errorf("wrong short classpath, got %v, error %v", cp, err)
+	}
+
+	os.WriteFile(cpFile, []byte(strings.Repeat("a", maxInlineClassPath+1)), 0644)
+	cp, err = readClassPath(cpFile)
+	if err != nil || cp.String() != "@"+cpFile {
+		t.Errorf("wrong long classpath, got %v, error %v", cp, err)
+	}
+
+	_, err = readClassPath("not-existing-" + cpFile)
+	if err == nil {
+		t.Error("expected to get an error for not existing classpath file")
+	}
+}
+
+func TestClassPathEntries(t *testing.T) {
+	cpFile := "test.cp"
+	defer os.Remove(cpFile)
+
+	sep := string(os.PathListSeparator)
+	expected := []string{"src", "a.jar", "b.jar"}
+
+	// inline, and in an argument file, also quoted
+	os.WriteFile(cpFile, []byte("\"src"+sep+"a.jar"+sep+"b.jar\"\n"), 0644)
+	for _, cp := range []classPath{{value: "src" + sep + "a.jar" + sep + sep + "b.jar"}, {argFile: cpFile}} {
+		res, err := cp.entries()
+		if err != nil {
+			t.Errorf("failed to get classpath entries: %v", err)
+		}
+		if join(res, "|") != join(expected, "|") {
+			t.Errorf("wrong classpath entries, expected %v, got %v", expected, res)
+		}
+	}
+}
+
+func TestClassPathMissingEntries(t *testing.T) {
+	jar := "test-lib.jar"
+	dir := "test-lib-dir"
+	defer os.Remove(jar)
+	defer os.RemoveAll(dir)
+
+	os.WriteFile(jar, []byte{}, 0644)
+	os.MkdirAll(filepath.Join(dir, "src"), os.ModePerm)
+	absDir, _ := filepath.Abs(dir)
+	removedDir, _ := filepath.Abs("not-existing-" + dir)
+
+	gitlibs := t.TempDir()
+	t.Setenv("GITLIBS", gitlibs)
+	checkout := filepath.Join(gitlibs, "libs", "io.github.user", "lib", "abc123")
+	os.MkdirAll(filepath.Join(checkout, "src"), os.ModePerm)
+	removedCheckout := filepath.Join(gitlibs, "libs", "io.github.user", "lib", "def456")
+
+	sep := string(os.PathListSeparator)
+	cp := classPath{value: join([]string{"src", "resources", jar, "not-existing.jar",
+		filepath.Join(absDir, "src"), filepath.Join(absDir, "resources"),
+		filepath.Join(removedDir, "src"), "/not-a-dep/src",
+		filepath.Join(checkout, "src"), filepath.Join(checkout, "resources"),
+		filepath.Join(removedCheckout, "src")}, sep)}
+	roots := []string{absDir, removedDir}
+	res, err := cp.missingEntries(newFileStats(), roots)
+	if err != nil {
+		t.Errorf("failed to get missing classpath entries: %v", err)
+	}
+	expected := []string{"not-existing.jar", filepath.Join(removedDir, "src"), filepath.Join(removedCheckout, "src")}
+	if join(res, "|") != join(expected, "|") {
+		t.Errorf("wrong missing classpath entries, expected %v, got %v", expected, res)
+	}
+}
+
+func TestDepRoots(t *testing.T) {
+	manifestFile := "test.manifest"
+	defer os.Remove(manifestFile)
+
+	roots, err := depRoots(manifestFile)
+	if err != nil || len(roots) != 0 {
+		t.Errorf("wrong dep roots without a manifest file, got %v, %v", roots, err)
+	}
+
+	os.WriteFile(manifestFile, []byte("/deps/a/deps.edn\n/deps/a/b/deps.edn\n"), 0644)
+	roots, err = depRoots(manifestFile)
+	if err != nil || join(roots, "|") != "/deps/a|/deps/a/b" {
+		t.Errorf("wrong dep roots, got %v, %v", roots, err)
+	}
+	if r := depRootOf("/deps/a/b/src", "/gitlibs/libs", roots); r != "/deps/a/b" {
+		t.Errorf("wrong dep root, expected the longest one, got %v", r)
+	}
+	if r := depRootOf("/deps/ab/src", "/gitlibs/libs", roots); r != "" {
+		t.Errorf("wrong dep root of a directory of no dep, got %v", r)
+	}
+	if r := depRootOf("/gitlibs/libs/io.github.user/lib/abc123/src", "/gitlibs/libs", roots); r != "/gitlibs/libs/io.github.user/lib/abc123" {
+		t.Errorf("wrong dep root of a gitlibs checkout, got %v", r)
+	}
+}
+
+func TestClassPathWithEntry(t *testing.T) {
+	cpFile := "test.cp"
+	defer os.Remove(cpFile)
+	defer os.Remove(cpFile + ".exec")
+
+	sep := string(os.PathListSeparator)
+
+	cp := classPath{value: "src"}.withEntry("exec.jar")
+	if cp.String() != "src"+sep+"exec.jar" {
+		t.Errorf("wrong inline classpath with entry, got %v", cp)
+	}
+
+	os.WriteFile(cpFile, []byte("src"+sep+"a.jar\n"), 0644)
+	cp = classPath{argFile: cpFile}.withEntry("exec.jar")
+	if cp.String() != "@"+cpFile+".exec" {
+		t.Errorf("wrong argument file classpath with entry, got %v", cp)
+	}
+	b, _ := os.ReadFile(cpFile + ".exec")
+	if string(b) != "src"+sep+"a.jar"+sep+"exec.jar" {
+		t.Errorf("wrong argument file content, got %v", string(b))
+	}
+}
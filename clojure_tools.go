@@ -13,12 +13,13 @@
 package tools4clj
 
 import (
+	"archive/zip"
 	"errors"
-	"fmt"
 	"os"
-	"os/exec"
 	"path"
-	"runtime"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 const usage = `Version: ` + version + ` of clojure tools
@@ -72,8 +73,10 @@ clj-opts:
   -Srepro        Ignore the ~/.clojure/deps.edn config file
   -Sforce        Force recomputation of the classpath (don't use the cache)
   -Sverbose      Print important path info to console
+  -Sexplain      Print why the cached classpath is (or is not) stale
   -Sthreads N    Set specific number of download threads
   -Strace        Write a trace.edn file that traces deps expansion
+  -Scache-dir DIR  Cache classpaths in DIR, instead of the project .cpcache
   --             Stop parsing dep options and pass remaining arguments to clojure.main
   -version       Print the version to stderr and exit
   --version      Print the version to stdout and exit
@@ -108,6 +111,26 @@ t4c-opt:
                instead of the default rlwrap
 --native-args  Use unaltered, native, command line args parsing on Windows
                no need to set it on other platforms
+--t4c-quiet    Do not print tools4clj messages, like download progress
+--t4c-tools-version VERSION
+               Use another clojure tools version (installed on demand),
+               also set by T4C_TOOLS_VERSION, or pinned by the project in
+               a .clojure-cli-version file
+--t4c-versions List the installed clojure tools versions, with their size,
+               marking the active one, and exit
+--t4c-gc [--keep N]
+               Remove the installed clojure tools versions, except the N newest
+               ones (default 0), the active and the default one, and exit
+--t4c-install-from PATH
+               Install clojure tools from a local clojure-tools-X.tar.gz
+               (with its .sha256 file next to it), or unpacked directory, and exit
+--t4c-cache list|prune [--older-than AGE]|clear
+               List the cached classpaths of the project and the user cache,
+               or remove those not used for AGE (e.g. 30d, default 30d),
+               or all of them, and exit
+--t4c-check-update
+               Check for a newer tools4clj release, and exit with status 0
+               when up to date, or 2 when a newer release exists
 
 For more info, see:
   https://clojure.org/guides/install_clojure
@@ -119,27 +142,120 @@ For more info, see:
   https://github.com/tasosx/tools4clj
 `
 
+// When bumping the version, record the sha256 of its tarball, in
+// tools_sha256.go, with go generate.
+//
+//go:generate go run gen_sha256.go
+
 const (
 	version        = "1.12.3.1577"
 	depsEDN        = "deps.edn"
 	exampleDepsEDN = "example-deps.edn"
 	cljToolsEDN    = "tools.edn"
-	toolsTarGz     = "clojure-tools-" + version + ".tar.gz"
-	toolsURL       = "https://github.com/clojure/brew-install/releases/download/" + version + "/" + toolsTarGz
-	toolsJar       = "clojure-tools-" + version + ".jar"
+	cliVersionFile = ".clojure-cli-version"
+	toolsInstalled = "t4c-installed"
+	// the install source of tools embedded in the binary
+	embeddedSource = "embedded"
+	stageSuffix    = ".tmp-"
 	libexecDir     = "libexec"
 	execJar        = "exec.jar"
 	t4cHome        = ".tools4clj"
 )
 
+// how long to wait for another process installing the tools
+const installLockTimeout = 10 * time.Minute
+
+// how long to wait for another process computing the same classpath
+const classpathLockTimeout = 10 * time.Minute
+
 var (
 	tools4CljDir = ""
 	toolsCp      = ""
 	execCp       = ""
 	javaPath     = ""
+	javaRuntime  = javaInfo{}
 	config       t4cConfig
 )
 
+// The clojure tools version in use, by default the one tools4clj is
+// based on, and the tools files names and urls that depend on it.
+var (
+	toolsVersion   = version
+	toolsTarGz     = "clojure-tools-" + version + ".tar.gz"
+	toolsURL       = "https://github.com/clojure/brew-install/releases/download/" + version + "/" + toolsTarGz
+	toolsMirrorURL = "https://download.clojure.org/install/" + toolsTarGz
+	toolsJar       = "clojure-tools-" + version + ".jar"
+)
+
+// Switch to another clojure tools version, for this run,
+// installed side by side in its own ~/.tools4clj/<version> directory.
+func setToolsVersion(v string) error {
+	if !isValidToolsVersion(v) {
+		return errors.New("invalid clojure tools version: " + v)
+	}
+
+	toolsVersion = v
+	toolsTarGz = "clojure-tools-" + v + ".tar.gz"
+	toolsURL = "https://github.com/clojure/brew-install/releases/download/" + v + "/" + toolsTarGz
+	toolsMirrorURL = "https://download.clojure.org/install/" + toolsTarGz
+	toolsJar = "clojure-tools-" + v + ".jar"
+	return nil
+}
+
+// resolve the install paths of the tools version, only
+// when running a command that needs clojure tools
+func initToolsPaths() error {
+	var err error
+	tools4CljDir, err = getTools4CljPath()
+	if err != nil {
+		return errors.New("could not find the home directory to install clojure tools in: " + err.Error())
+	}
+	toolsCp, err = getToolsCp(tools4CljDir)
+	if err != nil {
+		return err
+	}
+	execCp, err = getExecCp(tools4CljDir)
+	return err
+}
+
+// The clojure tools version pinned by the project, in a .clojure-cli-version
+// file, or as :t4c/tools-version in .t4c.edn; empty when not pinned.
+func projectToolsVersion() (string, error) {
+	if fileExists(cliVersionFile) {
+		lines, err := readNonEmptyLines(cliVersionFile)
+		if err != nil {
+			return "", err
+		}
+		if len(lines) > 0 {
+			v := strings.TrimSpace(lines[0])
+			if !isValidToolsVersion(v) {
+				return "", errors.New("invalid clojure tools version in " + cliVersionFile + ": " + v)
+			}
+			return v, nil
+		}
+	}
+
+	v := settings.value(":t4c/tools-version")
+	if v != "" && !isValidToolsVersion(v) {
+		return "", errors.New("invalid clojure tools version in :t4c/tools-version: " + v)
+	}
+	return v, nil
+}
+
+// a version is dot separated numbers, like 1.12.3.1577
+func isValidToolsVersion(v string) bool {
+	parts := strings.Split(v, ".")
+	if len(parts) < 2 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" || strings.Trim(part, "0123456789") != "" {
+			return false
+		}
+	}
+	return true
+}
+
 type t4cConfig struct {
 	configUser    string
 	configProject string
@@ -148,6 +264,9 @@ type t4cConfig struct {
 	mainFile      string
 	basisFile     string
 	manifestFile  string
+	digestsFile   string
+	metaFile      string
+	lockFile      string
 	toolsArgs     []string
 }
 
@@ -157,39 +276,29 @@ func buildCmdConfigs(conf *t4cConfig, cacheDir string, ck string) {
 	conf.mainFile = path.Join(cacheDir, ck+".main")
 	conf.basisFile = path.Join(cacheDir, ck+".basis")
 	conf.manifestFile = path.Join(cacheDir, ck+".manifest")
+	conf.digestsFile = path.Join(cacheDir, ck+".digests")
+	conf.metaFile = path.Join(cacheDir, ck+".meta")
+	conf.lockFile = path.Join(cacheDir, ck+".lock")
 }
 
-func getTools4CljPath() (string, error) {
+func getT4CHomePath() (string, error) {
 	env, found := os.LookupEnv("HOME")
 	if found {
-		return path.Join(env, t4cHome, version), nil
+		return path.Join(env, t4cHome), nil
 	}
 	env, error := os.UserHomeDir()
 	if error != nil {
 		return "", error
 	}
-	return path.Join(env, t4cHome, version), nil
+	return path.Join(env, t4cHome), nil
 }
 
-func getJavaPath() (string, error) {
-	env, found := os.LookupEnv("JAVA_CMD")
-	if found {
-		return env, nil
-	}
-
-	var p = exec.Command("java").Path
-	if p == "" {
-		env, found := os.LookupEnv("JAVA_HOME")
-		if !found {
-			return "", errors.New("could not find java executable - please set JAVA_HOME")
-		}
-		p = path.Join(env, "bin", "java")
-
-		if runtime.GOOS == "windows" {
-			p += ".exe"
-		}
+func getTools4CljPath() (string, error) {
+	t4cDir, err := getT4CHomePath()
+	if err != nil {
+		return "", err
 	}
-	return p, nil
+	return path.Join(t4cDir, toolsVersion), nil
 }
 
 func getToolsCp(toolsDir string) (string, error) {
@@ -208,59 +317,339 @@ func getExecCp(toolsDir string) (string, error) {
 	return path.Join(toolsDir, libexecDir, execJar), nil
 }
 
-func getClojureTools(toolsDir string) error {
-	err := os.MkdirAll(toolsDir, os.ModePerm)
+// Install the official clojure tools in toolsDir, when not already there.
+// A non empty localSource (a tarball or an unpacked directory) is used
+// in place of a download.
+//
+// The tools are downloaded and extracted in a staging directory, next to
+// toolsDir, and moved in place only when complete and valid, so an
+// interrupted install never leaves a broken toolsDir behind.
+func getClojureTools(toolsDir string, localSource string) error {
+	if toolsDir == "" {
+		return errors.New("empty install dir")
+	}
+
+	if isToolsInstalled(toolsDir) {
+		return nil
+	}
+
+	parentDir := path.Dir(toolsDir)
+	err := os.MkdirAll(parentDir, os.ModePerm)
 	if err != nil {
 		return err
 	}
 
-	allDirs := path.Join(toolsDir, libexecDir)
-	err = os.MkdirAll(allDirs, os.ModePerm)
+	// only one process installs, the others wait and reuse its install
+	lock, err := acquireLock(toolsLockPath(toolsDir),
+		envDuration("T4C_LOCK_TIMEOUT", installLockTimeout),
+		"waiting for another process to install clojure tools")
 	if err != nil {
 		return err
 	}
+	defer lock.release()
 
-	if fileExists(path.Join(toolsDir, libexecDir, toolsJar)) &&
-		fileExists(path.Join(toolsDir, libexecDir, execJar)) &&
-		fileExists(path.Join(toolsDir, depsEDN)) &&
-		fileExists(path.Join(toolsDir, exampleDepsEDN)) &&
-		fileExists(path.Join(toolsDir, cljToolsEDN)) {
+	if isToolsInstalled(toolsDir) {
 		return nil
 	}
 
-	fmt.Println("[t4c] - downloading official clojure tools")
+	if dirExists(toolsDir) {
+		t4cPrintln("found a partial clojure tools install, repairing")
+	}
 
-	// download the official clojure tools tar.gr
-	var tarPathTmp = path.Join(toolsDir, toolsTarGz)
-	if !fileExists(tarPathTmp) {
-		err = downloadFile(tarPathTmp, toolsURL)
+	// staging directories of interrupted installs
+	stale, err := filepath.Glob(path.Join(parentDir, "."+path.Base(toolsDir)+stageSuffix+"*"))
+	if err == nil {
+		for _, dir := range stale {
+			os.RemoveAll(dir)
+		}
 	}
+
+	stageDir, err := os.MkdirTemp(parentDir, "."+path.Base(toolsDir)+stageSuffix)
 	if err != nil {
 		return err
 	}
+	// on success the staging directory is already renamed
+	defer os.RemoveAll(stageDir)
 
-	fmt.Println("[t4c] - extracting needed clojure tools files")
+	err = os.Chmod(stageDir, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(path.Join(stageDir, libexecDir), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	// the tarball is kept out of the staging directory,
+	// so an interrupted download is resumed on the next run
+	var tarPathTmp = path.Join(parentDir, toolsTarGz)
+	var source string
+	if len(localSource) > 0 {
+		// use the local tarball or directory, as is
+		source, err = localClojureTools(localSource)
+		tarPathTmp = source
+	} else if hasEmbeddedTools() {
+		// materialize the tools embedded in this binary, no download
+		t4cPrintln("installing official clojure tools embedded in tools4clj")
+		source = embeddedSource
+	} else {
+		// download the official clojure tools tar.gz,
+		// trying the mirrors in order
+		source, err = downloadClojureTools(tarPathTmp, toolsURLs())
+	}
+	if err != nil {
+		return err
+	}
+
+	t4cPrintln("extracting needed clojure tools files")
 
 	// extract the needed files
-	err = pickFiles(toolsDir, tarPathTmp, []string{
+	if source == embeddedSource {
+		err = pickFSFiles(stageDir, embeddedTools, toolsFiles())
+	} else {
+		err = pickFiles(stageDir, tarPathTmp, toolsFiles())
+	}
+	if err != nil {
+		return err
+	}
+
+	err = validateClojureTools(stageDir)
+	if err != nil {
+		return err
+	}
+
+	t4cPrintln("cleaning up")
+
+	// remove the downloaded clojure tools tar.gz
+	if len(localSource) == 0 && source != embeddedSource {
+		err = os.Remove(tarPathTmp)
+		if err != nil {
+			return err
+		}
+	}
+
+	// mark the install as complete, keeping where the tools came from
+	err = os.WriteFile(path.Join(stageDir, toolsInstalled), []byte(source), 0644)
+	if err != nil {
+		return err
+	}
+
+	// replace any partial install, in one step
+	err = os.RemoveAll(toolsDir)
+	if err != nil {
+		return err
+	}
+	return os.Rename(stageDir, toolsDir)
+}
+
+// the embedded tools are of the default version only
+func hasEmbeddedTools() bool {
+	return embeddedTools != nil && toolsVersion == version
+}
+
+func toolsLockPath(toolsDir string) string {
+	return path.Join(path.Dir(toolsDir), path.Base(toolsDir)+".lock")
+}
+
+// A complete install has the installed marker. Installs made before the
+// marker was introduced are validated once, and marked.
+func isToolsInstalled(toolsDir string) bool {
+	if fileExists(path.Join(toolsDir, toolsInstalled)) {
+		return true
+	}
+	if !dirExists(toolsDir) || validateClojureTools(toolsDir) != nil {
+		return false
+	}
+	os.WriteFile(path.Join(toolsDir, toolsInstalled), []byte{}, 0644)
+	return true
+}
+
+// check that all tools files are there, and the jars are not truncated
+func validateClojureTools(toolsDir string) error {
+	missing := []string{}
+	for _, f := range toolsFiles() {
+		file := path.Join(toolsDir, f)
+		if strings.HasSuffix(f, ".jar") {
+			file = path.Join(toolsDir, libexecDir, f)
+		}
+		if !fileExists(file) {
+			missing = append(missing, f)
+			continue
+		}
+		if strings.HasSuffix(f, ".jar") {
+			jar, err := zip.OpenReader(file)
+			if err != nil {
+				return errors.New("invalid clojure tools jar " + file + ": " + err.Error())
+			}
+			jar.Close()
+		}
+	}
+	if len(missing) > 0 {
+		return errors.New("clojure tools install in " + toolsDir + " is missing: " + join(missing, ", "))
+	}
+	return nil
+}
+
+// validate a local clojure tools tarball or unpacked directory,
+// and return its absolute path
+func localClojureTools(localSource string) (string, error) {
+	source, err := filepath.Abs(localSource)
+	if err != nil {
+		return "", err
+	}
+
+	t4cPrintln("installing official clojure tools from " + source)
+
+	if dirExists(source) {
+		missing := missingFiles(toolsSourceDir(source), toolsFiles())
+		if len(missing) > 0 {
+			return "", errors.New("clojure tools directory " + source + " is missing: " + join(missing, ", "))
+		}
+		return source, nil
+	}
+	if !fileExists(source) {
+		return "", errors.New("clojure tools tarball or directory " + source + " does not exist")
+	}
+
+	t4cPrintln("verifying clojure tools checksum")
+
+	digest := toolsSha256Of(toolsVersion, source+".sha256")
+	if digest == "" {
+		return "", errors.New("no known sha256 digest to verify " + source +
+			", place its published .sha256 file next to it")
+	}
+
+	err = checkSha256(source, digest)
+	if err != nil {
+		return "", err
+	}
+	return source, nil
+}
+
+// the files installed from the official clojure tools
+func toolsFiles() []string {
+	return []string{
 		depsEDN,
 		exampleDepsEDN,
 		cljToolsEDN,
 		execJar,
 		toolsJar,
-	})
+	}
+}
+
+// The urls to download the clojure tools tarball from, in order of preference.
+// T4C_TOOLS_URL (or :t4c/tools-url in the tools4clj config.edn) may list
+// mirrors, either as a full tarball url or as a base url to append it to.
+func toolsURLs() []string {
+	mirrors := []string{}
+	env, found := os.LookupEnv("T4C_TOOLS_URL")
+	if found {
+		mirrors = strings.FieldsFunc(env, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\n'
+		})
+	} else {
+		mirrors = settings.values(":t4c/tools-url")
+	}
+	if len(mirrors) == 0 {
+		return []string{toolsURL, toolsMirrorURL}
+	}
+
+	urls := []string{}
+	for _, mirror := range mirrors {
+		if strings.HasSuffix(mirror, ".tar.gz") {
+			urls = append(urls, mirror)
+		} else {
+			urls = append(urls, strings.TrimSuffix(mirror, "/")+"/"+toolsTarGz)
+		}
+	}
+	return urls
+}
+
+// where the installed tools were downloaded from, if known
+func getToolsSource(toolsDir string) string {
+	b, err := os.ReadFile(path.Join(toolsDir, toolsInstalled))
 	if err != nil {
-		return err
+		return ""
 	}
+	return string(b)
+}
 
-	fmt.Println("[t4c] - cleaning up")
+// download and verify the tarball, trying each url until one succeeds,
+// and return the url that was used
+func downloadClojureTools(tarPath string, urls []string) (string, error) {
+	failures := []string{}
+	for _, url := range urls {
+		t4cPrintln("downloading official clojure tools from " + url)
+
+		err := downloadFile(tarPath, url)
+		if err == nil {
+			t4cPrintln("verifying clojure tools checksum")
+			err = checkSha256(tarPath, toolsSha256Of(toolsVersion, url+".sha256"))
+		}
+		if err == nil {
+			return url, nil
+		}
 
-	// remove the official clojure tools tar.gz
-	err = os.Remove(tarPathTmp)
+		// remove the failed (or corrupt) tar.gz, so the next try starts clean
+		os.Remove(tarPath)
+		failures = append(failures, url+": "+err.Error())
+	}
+	return "", errors.New("could not download clojure tools from any source:\n  " + strings.Join(failures, "\n  "))
+}
+
+// The expected digest of a clojure tools tarball, from the embedded table
+// when known, or else from its published .sha256 file (an url or a local
+// file), that is only as trusted as the host it comes from.
+func toolsSha256Of(toolsVersion string, sha256File string) string {
+	if digest, found := toolsSha256[toolsVersion]; found {
+		return digest
+	}
+
+	var text string
+	var err error
+	if strings.Contains(sha256File, "://") {
+		text, err = fetchText(sha256File)
+	} else {
+		var b []byte
+		b, err = os.ReadFile(sha256File)
+		text = string(b)
+	}
 	if err != nil {
-		return err
+		return ""
+	}
+	digest, err := parseSha256(text)
+	if err != nil {
+		return ""
 	}
+	return digest
+}
 
+// parse a sha256 file, either a plain digest or a "digest  filename" line
+func parseSha256(text string) (string, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return "", errors.New("empty sha256 digest")
+	}
+	digest := strings.ToLower(fields[0])
+	if len(digest) != 64 || strings.Trim(digest, "0123456789abcdef") != "" {
+		return "", errors.New("invalid sha256 digest: " + fields[0])
+	}
+	return digest, nil
+}
+
+func checkSha256(filename string, digest string) error {
+	if digest == "" {
+		return errors.New("no known sha256 digest to verify " + path.Base(filename))
+	}
+
+	actual, err := fileSha256(filename)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(digest, actual) {
+		return errors.New("sha256 mismatch for " + path.Base(filename) +
+			", expected " + digest + ", got " + actual)
+	}
 	return nil
 }
 
@@ -13,10 +13,22 @@
 package tools4clj
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
 func TestBuildCmdConfigs(t *testing.T) {
@@ -72,6 +84,98 @@ func TestGetTools4CljPath(t *testing.T) {
 	}
 }
 
+func TestSetToolsVersion(t *testing.T) {
+	defer setToolsVersion(version)
+
+	err := setToolsVersion("1.11.1.1429")
+	if err != nil {
+		t.Errorf("failed to set tools version: %v", err)
+	}
+	err = initToolsPaths()
+	if err != nil {
+		t.Errorf("failed to init tools paths: %v", err)
+	}
+	if toolsVersion != "1.11.1.1429" {
+		t.Errorf("wrong tools version, expected %v, got %v", "1.11.1.1429", toolsVersion)
+	}
+	if toolsJar != "clojure-tools-1.11.1.1429.jar" {
+		t.Errorf("wrong tools jar, got %v", toolsJar)
+	}
+	if toolsURL != "https://github.com/clojure/brew-install/releases/download/1.11.1.1429/clojure-tools-1.11.1.1429.tar.gz" {
+		t.Errorf("wrong tools url, got %v", toolsURL)
+	}
+	if !strings.HasSuffix(tools4CljDir, path.Join(t4cHome, "1.11.1.1429")) {
+		t.Errorf("wrong tools4clj dir, got %v", tools4CljDir)
+	}
+	if toolsCp != path.Join(tools4CljDir, libexecDir, toolsJar) {
+		t.Errorf("wrong tools class path, got %v", toolsCp)
+	}
+
+	err = setToolsVersion("../1.11")
+	if err == nil {
+		t.Error("expected to get an error for invalid tools version")
+	}
+	if toolsVersion != "1.11.1.1429" {
+		t.Errorf("tools version changed by invalid version, got %v", toolsVersion)
+	}
+}
+
+func TestProjectToolsVersion(t *testing.T) {
+	defer func() { settings = t4cSettings{} }()
+	defer os.Remove(cliVersionFile)
+
+	// not pinned
+	settings = t4cSettings{}
+	res, err := projectToolsVersion()
+	if err != nil || res != "" {
+		t.Errorf("expected no pinned version, got %v, error %v", res, err)
+	}
+
+	// pinned in .t4c.edn
+	settings = t4cSettings{":t4c/tools-version": []string{"1.12.0.1479"}}
+	res, err = projectToolsVersion()
+	if err != nil || res != "1.12.0.1479" {
+		t.Errorf("expected settings pinned version %v, got %v, error %v", "1.12.0.1479", res, err)
+	}
+
+	// pinned in .clojure-cli-version, overrides .t4c.edn
+	err = os.WriteFile(cliVersionFile, []byte("\n1.11.1.1429 \n"), 0644)
+	if err != nil {
+		t.Errorf("unable to write file: %v", err)
+		t.FailNow()
+	}
+	res, err = projectToolsVersion()
+	if err != nil || res != "1.11.1.1429" {
+		t.Errorf("expected file pinned version %v, got %v, error %v", "1.11.1.1429", res, err)
+	}
+
+	// invalid
+	os.WriteFile(cliVersionFile, []byte("latest"), 0644)
+	_, err = projectToolsVersion()
+	if err == nil {
+		t.Error("expected to get an error for invalid pinned version")
+	}
+}
+
+func TestIsValidToolsVersion(t *testing.T) {
+	testItems := map[string]bool{
+		"1.12.3.1577": true,
+		"1.10.1.754":  true,
+		"1":           false,
+		"":            false,
+		"1..2":        false,
+		"1.12.x":      false,
+		"../1.12":     false,
+		"1.12/..":     false,
+	}
+
+	for v, expected := range testItems {
+		if isValidToolsVersion(v) != expected {
+			t.Errorf("isValidToolsVersion of %v failed, expected %v", v, expected)
+		}
+	}
+}
+
 func TestGetJavaPath(t *testing.T) {
 	javaPath, err := getJavaPath()
 	if err != nil {
@@ -140,20 +244,546 @@ func TestGetExecJarPath(t *testing.T) {
 }
 
 func TestGetClojureTools(t *testing.T) {
+	defer func(backoff time.Duration) { downloadBackoff = backoff }(downloadBackoff)
+	downloadBackoff = 10 * time.Millisecond
+
 	dir := ""
-	err := getClojureTools(dir)
+	err := getClojureTools(dir, "")
 	if err == nil {
 		t.Errorf("expected to get an error for empty download dir creation")
 	}
 
 	dir = "testdata"
+	defer os.Remove(toolsLockPath(dir))
 
-	err = getClojureTools(dir)
+	err = getClojureTools(dir, "")
 	if err != nil {
 		t.Errorf("failed to download clojure tools with error: %v", err)
 	}
 }
 
+func TestToolsURLs(t *testing.T) {
+	envToolsURL, found := os.LookupEnv("T4C_TOOLS_URL")
+	if found {
+		defer os.Setenv("T4C_TOOLS_URL", envToolsURL)
+	}
+	defer func() { settings = t4cSettings{} }()
+
+	// defaults
+	os.Unsetenv("T4C_TOOLS_URL")
+	settings = t4cSettings{}
+	res := toolsURLs()
+	if len(res) != 2 || res[0] != toolsURL || res[1] != toolsMirrorURL {
+		t.Errorf("wrong default tools urls, got %v", res)
+	}
+
+	// settings
+	settings = t4cSettings{":t4c/tools-url": []string{"https://mirror.example.com/clojure/"}}
+	res = toolsURLs()
+	expected := "https://mirror.example.com/clojure/" + toolsTarGz
+	if len(res) != 1 || res[0] != expected {
+		t.Errorf("wrong settings tools urls, expected [%v], got %v", expected, res)
+	}
+
+	// environment overrides settings
+	os.Setenv("T4C_TOOLS_URL", "https://mirror1.example.com/clojure,https://mirror2.example.com/tools.tar.gz")
+	res = toolsURLs()
+	if len(res) != 2 ||
+		res[0] != "https://mirror1.example.com/clojure/"+toolsTarGz ||
+		res[1] != "https://mirror2.example.com/tools.tar.gz" {
+		t.Errorf("wrong environment tools urls, got %v", res)
+	}
+	os.Unsetenv("T4C_TOOLS_URL")
+}
+
+func TestDownloadClojureTools(t *testing.T) {
+	content := []byte("clojure tools tarball")
+	h := sha256.Sum256(content)
+	digest := hex.EncodeToString(h[:])
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/good/tools.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Write(content)
+	})
+	mux.HandleFunc("/good/tools.tar.gz.sha256", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(digest + "  tools.tar.gz\n"))
+	})
+	mux.HandleFunc("/corrupt/tools.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Write([]byte("corrupt tarball"))
+	})
+	mux.HandleFunc("/corrupt/tools.tar.gz.sha256", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(digest))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tarPath := "test-tools.tar.gz"
+	defer os.Remove(tarPath)
+
+	// falls back to the next source, on a failed download or a checksum mismatch
+	source, err := downloadClojureTools(tarPath, []string{
+		server.URL + "/missing/tools.tar.gz",
+		server.URL + "/corrupt/tools.tar.gz",
+		server.URL + "/good/tools.tar.gz",
+	})
+	if err != nil {
+		t.Errorf("failed to download clojure tools: %v", err)
+	}
+	if source != server.URL+"/good/tools.tar.gz" {
+		t.Errorf("wrong download source, got %v", source)
+	}
+	if !fileExists(tarPath) {
+		t.Error("downloaded tarball does not exist")
+	}
+
+	// all sources fail
+	_, err = downloadClojureTools(tarPath, []string{server.URL + "/corrupt/tools.tar.gz"})
+	if err == nil {
+		t.Error("expected to get an error when all sources fail")
+	}
+	if fileExists(tarPath) {
+		t.Error("corrupt tarball was not removed")
+	}
+}
+
+func TestParseSha256(t *testing.T) {
+	digest := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+	res, err := parseSha256(digest + "\n")
+	if err != nil {
+		t.Errorf("failed to parse plain sha256 digest: %v", err)
+	}
+	if res != digest {
+		t.Errorf("parse sha256 failed, expected %v, got %v", digest, res)
+	}
+
+	res, err = parseSha256(strings.ToUpper(digest) + "  clojure-tools.tar.gz\n")
+	if err != nil {
+		t.Errorf("failed to parse sha256sum formatted digest: %v", err)
+	}
+	if res != digest {
+		t.Errorf("parse sha256 failed, expected %v, got %v", digest, res)
+	}
+
+	_, err = parseSha256("")
+	if err == nil {
+		t.Errorf("expected to get an error for empty sha256 digest")
+	}
+
+	_, err = parseSha256("<html>Not Found</html>")
+	if err == nil {
+		t.Errorf("expected to get an error for invalid sha256 digest")
+	}
+}
+
+func TestCheckSha256(t *testing.T) {
+	tmpTestFile := "test-filename.tar.gz"
+	err := os.WriteFile(tmpTestFile, []byte("hello"), 0755)
+	if err != nil {
+		t.Errorf("Unable to write file: %v", err)
+		t.FailNow()
+	} else {
+		defer os.Remove(tmpTestFile)
+	}
+
+	digest := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	wrongDigest := "0000000000000000000000000000000000000000000000000000000000000000"
+
+	err = checkSha256(tmpTestFile, digest)
+	if err != nil {
+		t.Errorf("failed to check sha256: %v", err)
+	}
+
+	err = checkSha256(tmpTestFile, wrongDigest)
+	if err == nil {
+		t.Errorf("expected to get an error for sha256 mismatch")
+	}
+
+	err = checkSha256(tmpTestFile, "")
+	if err == nil {
+		t.Errorf("expected to get an error for no known sha256 digest")
+	}
+}
+
+func TestToolsSha256Of(t *testing.T) {
+	sha256File := "test-tools.tar.gz.sha256"
+	defer os.Remove(sha256File)
+
+	known := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	published := strings.Repeat("0", 64)
+	os.WriteFile(sha256File, []byte(published+"  test-tools.tar.gz\n"), 0644)
+
+	// the published digest, of a version not in the table
+	res := toolsSha256Of("0.0.0.1", sha256File)
+	if res != published {
+		t.Errorf("expected the published digest, got %v", res)
+	}
+
+	// the table is trusted over the published digest
+	toolsSha256["0.0.0.1"] = known
+	defer delete(toolsSha256, "0.0.0.1")
+	res = toolsSha256Of("0.0.0.1", sha256File)
+	if res != known {
+		t.Errorf("expected the known digest, got %v", res)
+	}
+
+	// no digest at all
+	res = toolsSha256Of("0.0.0.2", "not-existing.sha256")
+	if res != "" {
+		t.Errorf("expected no digest, got %v", res)
+	}
+}
+
+// the default version is verified against its known digest, recorded with
+// go generate when bumping it
+func TestToolsSha256Table(t *testing.T) {
+	if _, found := toolsSha256[version]; !found {
+		t.Errorf("no known sha256 digest of the default version %v, record it with: go generate", version)
+	}
+	for v, digest := range toolsSha256 {
+		_, err := parseSha256(digest)
+		if err != nil || !isValidToolsVersion(v) {
+			t.Errorf("invalid known digest of %v: %v", v, digest)
+		}
+	}
+}
+
+// write a clojure tools like tarball, with the given files under clojure-tools/
+func writeToolsTarGz(tarPath string, files map[string]string) error {
+	out, err := os.Create(tarPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		hdr := &tar.Header{
+			Name:     path.Join("clojure-tools", name),
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     int64(len(content)),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// a minimal, but valid, jar
+func testJar() string {
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	w, _ := zw.Create("META-INF/MANIFEST.MF")
+	w.Write([]byte("Manifest-Version: 1.0\n"))
+	zw.Close()
+	return b.String()
+}
+
+func testToolsFiles() map[string]string {
+	files := map[string]string{}
+	for _, f := range toolsFiles() {
+		if strings.HasSuffix(f, ".jar") {
+			files[f] = testJar()
+		} else {
+			files[f] = "content of " + f
+		}
+	}
+	return files
+}
+
+// install the test tools files directly in toolsDir
+func writeToolsDir(toolsDir string) error {
+	err := os.MkdirAll(path.Join(toolsDir, libexecDir), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	for name, content := range testToolsFiles() {
+		file := path.Join(toolsDir, name)
+		if strings.HasSuffix(name, ".jar") {
+			file = path.Join(toolsDir, libexecDir, name)
+		}
+		err = os.WriteFile(file, []byte(content), 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestGetClojureToolsEmbedded(t *testing.T) {
+	defer func(fsys fs.FS) { embeddedTools = fsys }(embeddedTools)
+	defer setToolsVersion(version)
+
+	toolsDir := "test-tools4clj-dir"
+	defer os.Remove(toolsLockPath(toolsDir))
+	defer os.RemoveAll(toolsDir)
+
+	fsys := fstest.MapFS{}
+	for name, content := range testToolsFiles() {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+	embeddedTools = fsys
+
+	// the embedded tools are only of the default version
+	setToolsVersion("1.11.1.1429")
+	if hasEmbeddedTools() {
+		t.Error("embedded tools used for a not default version")
+	}
+	setToolsVersion(version)
+	if !hasEmbeddedTools() {
+		t.Error("embedded tools not used for the default version")
+	}
+
+	err := getClojureTools(toolsDir, "")
+	if err != nil {
+		t.Errorf("failed to install embedded clojure tools: %v", err)
+	}
+	if !isToolsInstalled(toolsDir) || getToolsSource(toolsDir) != embeddedSource {
+		t.Errorf("embedded clojure tools not installed, source %v", getToolsSource(toolsDir))
+	}
+	b, _ := os.ReadFile(path.Join(toolsDir, depsEDN))
+	if string(b) != testToolsFiles()[depsEDN] {
+		t.Errorf("wrong content of installed %v, got %v", depsEDN, string(b))
+	}
+}
+
+func TestGetClojureToolsFromLocal(t *testing.T) {
+	toolsDir := "test-tools4clj-dir"
+	tarPath := "test-clojure-tools.tar.gz"
+	srcDir := "test-clojure-tools-src"
+	defer os.Remove(toolsLockPath(toolsDir))
+	defer os.RemoveAll(toolsDir)
+	defer os.Remove(tarPath)
+	defer os.Remove(tarPath + ".sha256")
+	defer os.RemoveAll(srcDir)
+
+	err := writeToolsTarGz(tarPath, testToolsFiles())
+	if err != nil {
+		t.Errorf("unable to write tarball: %v", err)
+		t.FailNow()
+	}
+
+	// no known digest
+	err = getClojureTools(toolsDir, tarPath)
+	if err == nil {
+		t.Error("expected to get an error for a local tarball without a known digest")
+	}
+
+	// with a wrong digest
+	os.WriteFile(tarPath+".sha256", []byte(strings.Repeat("0", 64)), 0644)
+	err = getClojureTools(toolsDir, tarPath)
+	if err == nil {
+		t.Error("expected to get an error for a local tarball with a wrong digest")
+	}
+
+	// with the published digest next to it
+	digest, _ := fileSha256(tarPath)
+	os.WriteFile(tarPath+".sha256", []byte(digest+"  "+tarPath), 0644)
+	err = getClojureTools(toolsDir, tarPath)
+	if err != nil {
+		t.Errorf("failed to install clojure tools from local tarball: %v", err)
+	}
+	if !fileExists(path.Join(toolsDir, libexecDir, toolsJar)) || !fileExists(path.Join(toolsDir, depsEDN)) {
+		t.Error("clojure tools files not installed from local tarball")
+	}
+	if !fileExists(tarPath) {
+		t.Error("local tarball should not be removed")
+	}
+	if !strings.HasSuffix(getToolsSource(toolsDir), tarPath) {
+		t.Errorf("wrong tools source, got %v", getToolsSource(toolsDir))
+	}
+
+	// of the default version, with its known digest and no .sha256 file
+	os.RemoveAll(toolsDir)
+	os.Remove(tarPath + ".sha256")
+	defer func(known string, found bool) {
+		if found {
+			toolsSha256[version] = known
+		} else {
+			delete(toolsSha256, version)
+		}
+	}(toolsSha256[version], toolsSha256[version] != "")
+	toolsSha256[version] = digest
+	err = getClojureTools(toolsDir, tarPath)
+	if err != nil {
+		t.Errorf("failed to install the default clojure tools from local tarball: %v", err)
+	}
+	if !isToolsInstalled(toolsDir) {
+		t.Error("default clojure tools not installed from local tarball")
+	}
+
+	// a tarball not matching the known digest, despite its .sha256 file
+	os.RemoveAll(toolsDir)
+	toolsSha256[version] = strings.Repeat("0", 64)
+	os.WriteFile(tarPath+".sha256", []byte(digest+"  "+tarPath), 0644)
+	err = getClojureTools(toolsDir, tarPath)
+	if err == nil {
+		t.Error("expected to get an error for a local tarball not matching the known digest")
+	}
+	os.Remove(tarPath + ".sha256")
+
+	// from an unpacked directory
+	os.RemoveAll(toolsDir)
+	os.MkdirAll(path.Join(srcDir, "clojure-tools"), os.ModePerm)
+	for name, content := range testToolsFiles() {
+		if name == toolsJar {
+			continue
+		}
+		os.WriteFile(path.Join(srcDir, "clojure-tools", name), []byte(content), 0644)
+	}
+	err = getClojureTools(toolsDir, srcDir)
+	if err == nil || !strings.Contains(err.Error(), toolsJar) {
+		t.Errorf("expected to get an error for the missing %v, got %v", toolsJar, err)
+	}
+
+	os.WriteFile(path.Join(srcDir, "clojure-tools", toolsJar), []byte(testJar()), 0644)
+	err = getClojureTools(toolsDir, srcDir)
+	if err != nil {
+		t.Errorf("failed to install clojure tools from local directory: %v", err)
+	}
+	if !fileExists(path.Join(toolsDir, libexecDir, toolsJar)) || !fileExists(path.Join(toolsDir, depsEDN)) {
+		t.Error("clojure tools files not installed from local directory")
+	}
+}
+
+func TestGetClojureToolsRepair(t *testing.T) {
+	toolsDir := "test-tools4clj-dir"
+	tarPath := "test-clojure-tools.tar.gz"
+	defer os.Remove(toolsLockPath(toolsDir))
+	defer os.RemoveAll(toolsDir)
+	defer os.Remove(tarPath)
+	defer os.Remove(tarPath + ".sha256")
+
+	err := writeToolsTarGz(tarPath, testToolsFiles())
+	if err != nil {
+		t.Errorf("unable to write tarball: %v", err)
+		t.FailNow()
+	}
+	digest, _ := fileSha256(tarPath)
+	os.WriteFile(tarPath+".sha256", []byte(digest), 0644)
+
+	// a partial install, with a truncated jar
+	err = writeToolsDir(toolsDir)
+	if err != nil {
+		t.Errorf("unable to write tools dir: %v", err)
+		t.FailNow()
+	}
+	jar := testJar()
+	os.WriteFile(path.Join(toolsDir, libexecDir, toolsJar), []byte(jar[:len(jar)/2]), 0644)
+
+	err = getClojureTools(toolsDir, tarPath)
+	if err != nil {
+		t.Errorf("failed to repair partial clojure tools install: %v", err)
+	}
+	if !fileExists(path.Join(toolsDir, toolsInstalled)) {
+		t.Error("repaired clojure tools install is not marked as installed")
+	}
+	err = validateClojureTools(toolsDir)
+	if err != nil {
+		t.Errorf("repaired clojure tools install is not valid: %v", err)
+	}
+
+	// waits for another process installing
+	os.RemoveAll(toolsDir)
+	lock, err := acquireLock(toolsLockPath(toolsDir), time.Second, "")
+	if err != nil {
+		t.Errorf("failed to acquire install lock: %v", err)
+		t.FailNow()
+	}
+	os.Setenv("T4C_LOCK_TIMEOUT", "300ms")
+	err = getClojureTools(toolsDir, tarPath)
+	os.Unsetenv("T4C_LOCK_TIMEOUT")
+	if err == nil {
+		t.Error("expected to get an error when install lock is held by another process")
+	}
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		lock.release()
+	}()
+	err = getClojureTools(toolsDir, tarPath)
+	if err != nil {
+		t.Errorf("failed to install after install lock was released: %v", err)
+	}
+
+	// no staging directories left behind
+	matches, _ := filepath.Glob("." + toolsDir + stageSuffix + "*")
+	if len(matches) != 0 {
+		t.Errorf("staging directories left behind: %v", matches)
+	}
+}
+
+func TestIsToolsInstalled(t *testing.T) {
+	toolsDir := "test-tools4clj-dir"
+	defer os.RemoveAll(toolsDir)
+
+	if isToolsInstalled(toolsDir) {
+		t.Error("not existing tools dir is installed")
+	}
+
+	// an install without the marker, is validated and marked
+	err := writeToolsDir(toolsDir)
+	if err != nil {
+		t.Errorf("unable to write tools dir: %v", err)
+		t.FailNow()
+	}
+	if !isToolsInstalled(toolsDir) {
+		t.Error("valid tools dir is not installed")
+	}
+	if !fileExists(path.Join(toolsDir, toolsInstalled)) {
+		t.Error("valid tools dir was not marked as installed")
+	}
+
+	// an invalid install without the marker
+	os.Remove(path.Join(toolsDir, toolsInstalled))
+	os.Remove(path.Join(toolsDir, depsEDN))
+	if isToolsInstalled(toolsDir) {
+		t.Error("invalid tools dir is installed")
+	}
+}
+
+func TestValidateClojureTools(t *testing.T) {
+	toolsDir := "test-tools4clj-dir"
+	defer os.RemoveAll(toolsDir)
+
+	err := writeToolsDir(toolsDir)
+	if err != nil {
+		t.Errorf("unable to write tools dir: %v", err)
+		t.FailNow()
+	}
+
+	err = validateClojureTools(toolsDir)
+	if err != nil {
+		t.Errorf("failed to validate clojure tools: %v", err)
+	}
+
+	// truncated jar
+	os.WriteFile(path.Join(toolsDir, libexecDir, execJar), []byte("PK"), 0644)
+	err = validateClojureTools(toolsDir)
+	if err == nil {
+		t.Error("expected to get an error for a truncated jar")
+	}
+
+	// missing files
+	os.Remove(path.Join(toolsDir, libexecDir, execJar))
+	os.Remove(path.Join(toolsDir, cljToolsEDN))
+	err = validateClojureTools(toolsDir)
+	if err == nil || !strings.Contains(err.Error(), execJar+", "+cljToolsEDN) &&
+		!strings.Contains(err.Error(), cljToolsEDN+", "+execJar) {
+		t.Errorf("expected to get an error for missing files, got %v", err)
+	}
+}
+
 func TestGetConfigPaths(t *testing.T) {
 	toolsDir := "testdata"
 	dir := "test-config-dir"
@@ -0,0 +1,235 @@
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// the staleness checks of cached classpaths, set by
+// T4C_STALE_CHECK, or :t4c/stale-check in .t4c.edn
+const (
+	staleCheckMtime   = "mtime"
+	staleCheckContent = "content"
+)
+
+func staleCheckMode() string {
+	mode, found := os.LookupEnv("T4C_STALE_CHECK")
+	if !found || mode == "" {
+		mode = settings.value(":t4c/stale-check")
+	}
+	if strings.TrimPrefix(mode, ":") == staleCheckContent {
+		return staleCheckContent
+	}
+	return staleCheckMtime
+}
+
+// the size, modification time and sha256 of a file, when it exists
+type fileDigest struct {
+	exists  bool
+	size    int64
+	modTime int64
+	sha256  string
+}
+
+// file digests, by file path, stored next to a cached classpath
+type fileDigests map[string]fileDigest
+
+func digestOf(file string) (fileDigest, error) {
+	info, err := os.Stat(file)
+	if os.IsNotExist(err) {
+		return fileDigest{}, nil
+	}
+	if err != nil {
+		return fileDigest{}, err
+	}
+	digest, err := fileSha256(file)
+	if err != nil {
+		return fileDigest{}, err
+	}
+	return fileDigest{exists: true, size: info.Size(), modTime: info.ModTime().UnixNano(), sha256: digest}, nil
+}
+
+func digestFiles(files []string) (fileDigests, error) {
+	digests := fileDigests{}
+	for _, file := range files {
+		d, err := digestOf(file)
+		if err != nil {
+			return nil, err
+		}
+		digests[file] = d
+	}
+	return digests, nil
+}
+
+// The first of the files that changed since their digests were recorded.
+// An unchanged size and modification time is trusted, any other file is
+// compared by content, and its recorded modification time is updated.
+func (recorded fileDigests) changed(files []string) (string, bool, error) {
+	refreshed := false
+	for _, file := range files {
+		rec, found := recorded[file]
+		if !found {
+			return file, false, nil
+		}
+
+		info, err := os.Stat(file)
+		if os.IsNotExist(err) {
+			if rec.exists {
+				return file, false, nil
+			}
+			continue
+		}
+		if err != nil {
+			return "", false, err
+		}
+		if !rec.exists {
+			return file, false, nil
+		}
+		if info.Size() == rec.size && info.ModTime().UnixNano() == rec.modTime {
+			continue
+		}
+
+		digest, err := fileSha256(file)
+		if err != nil {
+			return "", false, err
+		}
+		if digest != rec.sha256 {
+			return file, false, nil
+		}
+		rec.size = info.Size()
+		rec.modTime = info.ModTime().UnixNano()
+		recorded[file] = rec
+		refreshed = true
+	}
+	return "", refreshed, nil
+}
+
+// one line per file: sha256 (or - when missing), size, modification time, path
+func writeDigests(digestsFile string, digests fileDigests) error {
+	text := ""
+	for file, d := range digests {
+		if d.exists {
+			text += d.sha256 + " " + strconv.FormatInt(d.size, 10) + " " + strconv.FormatInt(d.modTime, 10) + " " + file + "\n"
+		} else {
+			text += "- 0 0 " + file + "\n"
+		}
+	}
+	return os.WriteFile(digestsFile, []byte(text), 0644)
+}
+
+func readDigests(digestsFile string) (fileDigests, error) {
+	lines, err := readNonEmptyLines(digestsFile)
+	if err != nil {
+		return nil, err
+	}
+	digests := fileDigests{}
+	for _, line := range lines {
+		fields := strings.SplitN(line, " ", 4)
+		if len(fields) != 4 {
+			return nil, errors.New("invalid digests file " + digestsFile)
+		}
+		size, err1 := strconv.ParseInt(fields[1], 10, 64)
+		modTime, err2 := strconv.ParseInt(fields[2], 10, 64)
+		if err1 != nil || err2 != nil {
+			return nil, errors.New("invalid digests file " + digestsFile)
+		}
+		if fields[0] == "-" {
+			digests[fields[3]] = fileDigest{}
+		} else {
+			digests[fields[3]] = fileDigest{exists: true, size: size, modTime: modTime, sha256: fields[0]}
+		}
+	}
+	return digests, nil
+}
+
+// the files a cached classpath depends on: the config paths,
+// the tool file, and the manifests listed by clojure tools
+func classpathInputs(options *allOpts, config t4cConfig, configPaths []string) ([]string, error) {
+	files := append([]string{}, configPaths...)
+	if len(options.Clj.ToolName) > 0 {
+		configDir, err := getConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, path.Join(getCljToolsDir(configDir), options.Clj.ToolName+".edn"))
+	}
+	if fileExists(config.manifestFile) {
+		manifests, err := readNonEmptyLines(config.manifestFile)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, manifests...)
+	}
+	return files, nil
+}
+
+// record the digests of the classpath inputs, after computing the classpath
+func recordClasspathInputs(options *allOpts, config t4cConfig, configPaths []string) error {
+	files, err := classpathInputs(options, config, configPaths)
+	if err != nil {
+		return err
+	}
+	digests, err := digestFiles(files)
+	if err != nil {
+		return err
+	}
+	return writeDigests(config.digestsFile, digests)
+}
+
+// content based staleness, the classpath is stale when any of its inputs
+// changed, or when its inputs were never recorded
+func isContentStale(options *allOpts, config t4cConfig, configPaths []string, stats *fileStats, explain func(string)) (bool, error) {
+	stale, err := hasMissingEntries(config, stats, explain)
+	if err != nil || stale {
+		return stale, err
+	}
+	if stats.fileExists(options.Clj.DepsData) && (options.Clj.DepsData != config.cpFile) {
+		explain("-Sdeps file " + options.Clj.DepsData + " is used: stale")
+		return true, nil
+	}
+
+	if !fileExists(config.digestsFile) {
+		explain("digests file " + config.digestsFile + " does not exist: stale")
+		return true, nil
+	}
+	recorded, err := readDigests(config.digestsFile)
+	if err != nil {
+		explain(err.Error() + ": stale")
+		return true, nil
+	}
+	files, err := classpathInputs(options, config, configPaths)
+	if err != nil {
+		return false, err
+	}
+
+	changed, refreshed, err := recorded.changed(files)
+	if err != nil {
+		return false, err
+	}
+	if changed != "" {
+		explain("content of " + changed + ", modified " + stats.modTime(changed) + ", changed since recorded: stale")
+		return true, nil
+	}
+	if refreshed {
+		// so the next run trusts the new modification times
+		writeDigests(config.digestsFile, recorded)
+		explain("modified, but not changed, files recorded again in " + config.digestsFile)
+	}
+	explain("contents of " + strconv.Itoa(len(files)) + " config paths and manifests not changed: up to date")
+	return false, nil
+}
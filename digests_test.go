@@ -0,0 +1,141 @@
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStaleCheckMode(t *testing.T) {
+	env, found := os.LookupEnv("T4C_STALE_CHECK")
+	if found {
+		defer os.Setenv("T4C_STALE_CHECK", env)
+	} else {
+		defer os.Unsetenv("T4C_STALE_CHECK")
+	}
+	defer func() { settings = t4cSettings{} }()
+
+	os.Unsetenv("T4C_STALE_CHECK")
+	settings = t4cSettings{}
+	if staleCheckMode() != staleCheckMtime {
+		t.Errorf("wrong default stale check mode, got %v", staleCheckMode())
+	}
+
+	settings = t4cSettings{":t4c/stale-check": []string{":content"}}
+	if staleCheckMode() != staleCheckContent {
+		t.Errorf("wrong settings stale check mode, got %v", staleCheckMode())
+	}
+
+	os.Setenv("T4C_STALE_CHECK", "mtime")
+	if staleCheckMode() != staleCheckMtime {
+		t.Errorf("wrong environment stale check mode, got %v", staleCheckMode())
+	}
+}
+
+func TestReadWriteDigests(t *testing.T) {
+	configFile := "test config.edn"
+	digestsFile := "test.digests"
+	defer os.Remove(configFile)
+	defer os.Remove(digestsFile)
+
+	os.WriteFile(configFile, []byte("{:deps {}}"), 0644)
+	digests, err := digestFiles([]string{configFile, "not-existing.edn"})
+	if err != nil {
+		t.Errorf("failed to digest files: %v", err)
+	}
+	if !digests[configFile].exists || digests["not-existing.edn"].exists {
+		t.Errorf("wrong file digests, got %+v", digests)
+	}
+
+	err = writeDigests(digestsFile, digests)
+	if err != nil {
+		t.Errorf("failed to write digests: %v", err)
+	}
+	res, err := readDigests(digestsFile)
+	if err != nil {
+		t.Errorf("failed to read digests: %v", err)
+	}
+	if len(res) != 2 || res[configFile] != digests[configFile] || res["not-existing.edn"] != digests["not-existing.edn"] {
+		t.Errorf("wrong read digests, expected %+v, got %+v", digests, res)
+	}
+
+	os.WriteFile(digestsFile, []byte("garbage\n"), 0644)
+	_, err = readDigests(digestsFile)
+	if err == nil {
+		t.Error("expected to get an error for invalid digests file")
+	}
+}
+
+func TestIsContentStale(t *testing.T) {
+	options := allOpts{}
+	configFile := "test-config.edn"
+	config := t4cConfig{
+		cpFile:       "test.cp",
+		manifestFile: "test.manifest",
+		digestsFile:  "test.digests",
+	}
+	defer os.Remove(configFile)
+	defer os.Remove(config.cpFile)
+	defer os.Remove(config.digestsFile)
+	configPaths := []string{configFile, "not-existing.edn"}
+
+	os.WriteFile(configFile, []byte("{:deps {}}"), 0644)
+	os.WriteFile(config.cpFile, []byte("src"), 0644)
+
+	// never recorded
+	stale, err := isContentStale(&options, config, configPaths, newFileStats(), noExplain)
+	if err != nil || !stale {
+		t.Errorf("expected stale classpath, when never recorded, got %v, error %v", stale, err)
+	}
+
+	err = recordClasspathInputs(&options, config, configPaths)
+	if err != nil {
+		t.Errorf("failed to record classpath inputs: %v", err)
+	}
+	stale, err = isContentStale(&options, config, configPaths, newFileStats(), noExplain)
+	if err != nil || stale {
+		t.Errorf("expected not stale classpath, got %v, error %v", stale, err)
+	}
+
+	// touched, as by a git checkout, but not changed
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(configFile, later, later)
+	stale, err = isContentStale(&options, config, configPaths, newFileStats(), noExplain)
+	if err != nil || stale {
+		t.Errorf("expected not stale classpath, when touched, got %v, error %v", stale, err)
+	}
+	recorded, _ := readDigests(config.digestsFile)
+	if recorded[configFile].modTime != later.UnixNano() {
+		t.Error("recorded modification time not updated, when touched")
+	}
+
+	// changed, with an older modification time, as by rsync or clock skew
+	os.WriteFile(configFile, []byte("{:deps {org.clojure/clojure {:mvn/version \"1.12.3\"}}}"), 0644)
+	earlier := time.Now().Add(-time.Hour)
+	os.Chtimes(configFile, earlier, earlier)
+	stale, err = isContentStale(&options, config, configPaths, newFileStats(), noExplain)
+	if err != nil || !stale {
+		t.Errorf("expected stale classpath, when changed, got %v, error %v", stale, err)
+	}
+
+	// a new config path
+	recordClasspathInputs(&options, config, configPaths)
+	os.WriteFile("not-existing.edn", []byte("{}"), 0644)
+	defer os.Remove("not-existing.edn")
+	stale, err = isContentStale(&options, config, configPaths, newFileStats(), noExplain)
+	if err != nil || !stale {
+		t.Errorf("expected stale classpath, when a config path is created, got %v, error %v", stale, err)
+	}
+}
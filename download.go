@@ -0,0 +1,212 @@
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	downloadRetries = 4
+	connectTimeout  = 30 * time.Second
+	readTimeout     = 60 * time.Second
+)
+
+// the wait before the first retry, doubled on every next one
+var downloadBackoff = 2 * time.Second
+
+// An http client that honors HTTP(S)_PROXY and NO_PROXY, with connect and
+// read timeouts (T4C_CONNECT_TIMEOUT, T4C_READ_TIMEOUT), trusting also the
+// certificates of the T4C_CA_BUNDLE pem file, when set.
+func httpClient() (*http.Client, error) {
+	timeout := envDuration("T4C_CONNECT_TIMEOUT", connectTimeout)
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   timeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: envDuration("T4C_READ_TIMEOUT", readTimeout),
+	}
+
+	caBundle, found := os.LookupEnv("T4C_CA_BUNDLE")
+	if found && caBundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(caBundle)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in CA bundle " + caBundle)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// Download url to filepath, retrying with exponential backoff on network
+// and server errors. The data is written to filepath.part, resumed there
+// on a next try, and renamed to filepath once complete.
+func downloadFile(filepath string, url string) error {
+	client, err := httpClient()
+	if err != nil {
+		return err
+	}
+
+	delay := downloadBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := downloadPart(client, filepath+".part", url)
+		if err == nil {
+			return os.Rename(filepath+".part", filepath)
+		}
+		if !retry || attempt >= downloadRetries {
+			return err
+		}
+
+		t4cPrintln("download failed (" + err.Error() + "), retrying in " + delay.String())
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// download (or resume) to partPath, and tell whether a failure is worth a retry
+func downloadPart(client *http.Client, partPath string, url string) (bool, error) {
+	var offset int64
+	info, err := os.Stat(partPath)
+	if err == nil {
+		offset = info.Size()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0 &&
+		strings.HasPrefix(resp.Header.Get("Content-Range"), "bytes "+strconv.FormatInt(offset, 10)+"-"):
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusOK:
+		// no resume support, start over
+		flags |= os.O_TRUNC
+	case resp.StatusCode == http.StatusPartialContent ||
+		resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// the partial download is not valid for this url
+		os.Remove(partPath)
+		return true, errors.New(url + ": " + resp.Status)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout ||
+		resp.StatusCode >= http.StatusInternalServerError:
+		return true, errors.New(url + ": " + resp.Status)
+	default:
+		return false, errors.New(url + ": " + resp.Status)
+	}
+
+	// an html error (or login) page is not a download
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if strings.HasPrefix(mediaType, "text/") {
+		return false, errors.New(url + ": unexpected content type " + mediaType)
+	}
+
+	out, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return false, err
+	}
+	defer out.Close()
+
+	// cancel the download when stalled for longer than the read timeout
+	stall := envDuration("T4C_READ_TIMEOUT", readTimeout)
+	body := &stallReader{reader: resp.Body, timeout: stall, timer: time.AfterFunc(stall, cancel)}
+	defer body.timer.Stop()
+
+	var total int64
+	if resp.ContentLength > 0 {
+		total = offset + resp.ContentLength
+	}
+	var dest io.Writer = out
+	progress := newStderrProgress(offset, total)
+	if progress != nil {
+		dest = io.MultiWriter(out, progress)
+		defer progress.done()
+	}
+
+	_, err = io.Copy(dest, body)
+	if err != nil {
+		return true, err
+	}
+	return false, out.Sync()
+}
+
+// a reader that postpones its timer on every read
+type stallReader struct {
+	reader  io.Reader
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+func (r *stallReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.timer.Reset(r.timeout)
+	return n, err
+}
+
+func fetchText(url string) (string, error) {
+	client, err := httpClient()
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New("could not fetch " + url + ": " + resp.Status)
+	}
+
+	// a text resource, so a small read limit is enough
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
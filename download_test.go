@@ -0,0 +1,168 @@
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHttpClient(t *testing.T) {
+	envCABundle, found := os.LookupEnv("T4C_CA_BUNDLE")
+	if found {
+		defer os.Setenv("T4C_CA_BUNDLE", envCABundle)
+	}
+
+	os.Unsetenv("T4C_CA_BUNDLE")
+	_, err := httpClient()
+	if err != nil {
+		t.Errorf("failed to create http client: %v", err)
+	}
+
+	os.Setenv("T4C_CA_BUNDLE", "not-existing-ca-bundle.pem")
+	_, err = httpClient()
+	if err == nil {
+		t.Error("expected to get an error for not existing CA bundle")
+	}
+
+	tmpTestFile := "test-ca-bundle.pem"
+	os.WriteFile(tmpTestFile, []byte("not a certificate"), 0644)
+	defer os.Remove(tmpTestFile)
+
+	os.Setenv("T4C_CA_BUNDLE", tmpTestFile)
+	_, err = httpClient()
+	if err == nil {
+		t.Error("expected to get an error for CA bundle without certificates")
+	}
+	os.Unsetenv("T4C_CA_BUNDLE")
+}
+
+func TestDownloadFile(t *testing.T) {
+	defer func(backoff time.Duration) { downloadBackoff = backoff }(downloadBackoff)
+	downloadBackoff = 10 * time.Millisecond
+
+	content := bytes.Repeat([]byte("clojure tools "), 1024)
+	failures := 0
+	ranges := []string{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tools.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("Content-Type", "application/gzip")
+		http.ServeContent(w, r, "tools.tar.gz", time.Now(), bytes.NewReader(content))
+	})
+	mux.HandleFunc("/flaky.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		if failures < 2 {
+			failures++
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Write(content)
+	})
+	mux.HandleFunc("/login.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html>please login</html>"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tmpTestFile := "test-download.tar.gz"
+	defer os.Remove(tmpTestFile)
+	defer os.Remove(tmpTestFile + ".part")
+
+	// plain download
+	err := downloadFile(tmpTestFile, server.URL+"/tools.tar.gz")
+	if err != nil {
+		t.Errorf("failed to download file: %v", err)
+	}
+	b, _ := os.ReadFile(tmpTestFile)
+	if !bytes.Equal(b, content) {
+		t.Error("downloaded file content differs")
+	}
+	if fileExists(tmpTestFile + ".part") {
+		t.Error("partial download file left behind")
+	}
+
+	// resume a partial download
+	os.Remove(tmpTestFile)
+	os.WriteFile(tmpTestFile+".part", content[:1000], 0644)
+	ranges = []string{}
+	err = downloadFile(tmpTestFile, server.URL+"/tools.tar.gz")
+	if err != nil {
+		t.Errorf("failed to resume download: %v", err)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=1000-" {
+		t.Errorf("download was not resumed, ranges requested %v", ranges)
+	}
+	b, _ = os.ReadFile(tmpTestFile)
+	if !bytes.Equal(b, content) {
+		t.Error("resumed file content differs")
+	}
+
+	// retry on server errors
+	os.Remove(tmpTestFile)
+	err = downloadFile(tmpTestFile, server.URL+"/flaky.tar.gz")
+	if err != nil {
+		t.Errorf("failed to download after retries: %v", err)
+	}
+	if failures != 2 {
+		t.Errorf("expected %v failed tries, got %v", 2, failures)
+	}
+
+	// not found
+	os.Remove(tmpTestFile)
+	err = downloadFile(tmpTestFile, server.URL+"/missing.tar.gz")
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected to get a not found error, got %v", err)
+	}
+	if fileExists(tmpTestFile) {
+		t.Error("not found download was saved")
+	}
+
+	// html page
+	err = downloadFile(tmpTestFile, server.URL+"/login.tar.gz")
+	if err == nil || !strings.Contains(err.Error(), "text/html") {
+		t.Errorf("expected to get a content type error, got %v", err)
+	}
+	if fileExists(tmpTestFile) {
+		t.Error("html page was saved as download")
+	}
+}
+
+func TestFetchText(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tools.tar.gz.sha256", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("digest"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	res, err := fetchText(server.URL + "/tools.tar.gz.sha256")
+	if err != nil {
+		t.Errorf("failed to fetch text: %v", err)
+	}
+	if res != "digest" {
+		t.Errorf("wrong fetched text, expected %v, got %v", "digest", res)
+	}
+
+	_, err = fetchText(server.URL + "/missing.sha256")
+	if err == nil {
+		t.Error("expected to get an error for not found text")
+	}
+}
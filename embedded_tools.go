@@ -0,0 +1,42 @@
+//go:build embedtools
+
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// Fill the embedded directory, and embedded_version.go, with the verified
+// clojure tools files of the default version, before building.
+//
+//go:generate go run gen_embedded.go
+
+// The clojure tools files of the default version, extracted in the
+// embedded directory by go generate -tags embedtools (see README).
+//
+//go:embed embedded
+var embeddedFiles embed.FS
+
+var embeddedTools = func() fs.FS {
+	sub, err := fs.Sub(embeddedFiles, "embedded")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}()
+
+// a stale embed, of another clojure tools version, fails the build
+var _ = map[bool]int{false: 0, embeddedVersion == version: 1}
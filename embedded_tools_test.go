@@ -0,0 +1,32 @@
+//go:build embedtools
+
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"io/fs"
+	"testing"
+)
+
+func TestEmbeddedTools(t *testing.T) {
+	if embeddedSha256 != toolsSha256[version] {
+		t.Errorf("embedded clojure tools of digest %v, expected %v", embeddedSha256, toolsSha256[version])
+	}
+	for _, f := range toolsFiles() {
+		_, err := fs.Stat(embeddedTools, f)
+		if err != nil {
+			t.Errorf("clojure tools file %v is not embedded: %v", f, err)
+		}
+	}
+}
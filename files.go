@@ -14,12 +14,16 @@ package tools4clj
 
 import (
 	"archive/tar"
+	"bytes"
 	"compress/gzip"
-	"fmt"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"io"
-	"net/http"
+	"io/fs"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 )
 
@@ -75,24 +79,18 @@ func dirExists(dirname string) bool {
 	return info.IsDir()
 }
 
-func downloadFile(filepath string, url string) error {
-	// Get the data
-	resp, err := http.Get(url)
+func fileSha256(filename string) (string, error) {
+	file, err := os.Open(filename)
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer resp.Body.Close()
+	defer file.Close()
 
-	// Create the file
-	out, err := os.Create(filepath)
-	if err != nil {
-		return err
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
 	}
-	defer out.Close()
-
-	// Write the body to file
-	_, err = io.Copy(out, resp.Body)
-	return err
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
 func copyFile(dest string, src string) error {
@@ -122,25 +120,27 @@ func copyFile(dest string, src string) error {
 }
 
 func checkIsNewerFile(file1 string, file2 string) (bool, error) {
-	if !fileExists(file1) {
-		return false, nil
-	}
-	if !fileExists(file2) {
-		return true, nil
-	}
 	sfile1, err := os.Stat(file1)
-	if err != nil {
-		return false, err
+	if err != nil || sfile1.IsDir() {
+		return false, nil
 	}
 	sfile2, err := os.Stat(file2)
-	if err != nil {
-		return false, err
+	if err != nil || sfile2.IsDir() {
+		return true, nil
 	}
 
 	return sfile1.ModTime().UnixNano() > sfile2.ModTime().UnixNano(), nil
 }
 
+// the largest clojure tools file accepted, the jar is just a few MB
+const maxToolsFileSize = 256 * 1024 * 1024
+
+// pick the files from a tarball, or an unpacked clojure tools directory
 func pickFiles(toolsDir string, tarPath string, files []string) error {
+	if dirExists(tarPath) {
+		return pickDirFiles(toolsDir, tarPath, files)
+	}
+
 	tarFile, err := os.Open(tarPath)
 	if err != nil {
 		return err
@@ -153,6 +153,7 @@ func pickFiles(toolsDir string, tarPath string, files []string) error {
 	}
 	defer gz.Close()
 
+	picked := map[string]bool{}
 	tr := tar.NewReader(gz)
 	for {
 		hdr, err := tr.Next()
@@ -163,46 +164,144 @@ func pickFiles(toolsDir string, tarPath string, files []string) error {
 			return err
 		}
 
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return errors.New("unsafe path in " + tarPath + ": " + hdr.Name)
+		}
+
 		for _, f := range files {
-			saveFrom := path.Join("clojure-tools", f)
-			if hdr.Name == saveFrom {
-				saveTo := path.Join(toolsDir, f)
-				if strings.HasSuffix(f, ".jar") {
-					saveTo = path.Join(toolsDir, libexecDir, f)
-				}
-				saveFile, err := os.Create(saveTo)
-				if err != nil {
-					return err
-				}
-
-				if _, err := io.Copy(saveFile, tr); err != nil {
-					return err
-				}
-				fmt.Printf("[t4c] - %s: ", hdr.Name)
-				fmt.Printf("... copied to %s", path.Join(f))
-				fmt.Println()
+			if name != path.Join("clojure-tools", f) {
+				continue
+			}
+			if hdr.Typeflag != tar.TypeReg {
+				return errors.New("unexpected type of " + hdr.Name + " in " + tarPath + ", not a regular file")
 			}
+			if hdr.Size < 0 || hdr.Size > maxToolsFileSize {
+				return errors.New("unexpected size of " + hdr.Name + " in " + tarPath)
+			}
+
+			err := saveFile(toolsFilePath(toolsDir, f), tr, hdr.Size, os.FileMode(hdr.Mode))
+			if err != nil {
+				return err
+			}
+			picked[f] = true
+			t4cPrintln(hdr.Name + ": ... copied to " + path.Join(f))
 		}
 	}
+
+	missing := []string{}
+	for _, f := range files {
+		if !picked[f] {
+			missing = append(missing, f)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.New(tarPath + " is missing: " + join(missing, ", "))
+	}
 	return nil
 }
 
-func getExecCpFile(cp string, execJarPath string) string {
-	cpFile := cp
-	if strings.HasPrefix(cp, "@") {
-		cpOriginalFile := strings.TrimPrefix(cp, "@")
-		cpFile = cpOriginalFile + ".exec"
-		newer, _ := checkIsNewerFile(cpFile, cpOriginalFile)
-		if !newer {
-			copyFile(cpFile, cpOriginalFile)
-
-			f, _ := os.OpenFile(cpFile, os.O_APPEND|os.O_WRONLY, 0644)
-			defer f.Close()
-			f.Write([]byte(string(os.PathListSeparator) + execJarPath))
+func pickDirFiles(toolsDir string, srcDir string, files []string) error {
+	srcDir = toolsSourceDir(srcDir)
+	for _, f := range files {
+		src := path.Join(srcDir, f)
+		info, err := os.Lstat(src)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return errors.New("unexpected type of " + src + ", not a regular file")
+		}
+		if info.Size() > maxToolsFileSize {
+			return errors.New("unexpected size of " + src)
 		}
-		cpFile = "@" + cpFile
-	} else {
-		cpFile = cp + string(os.PathListSeparator) + execJarPath
+
+		srcFile, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		err = saveFile(toolsFilePath(toolsDir, f), srcFile, info.Size(), info.Mode())
+		srcFile.Close()
+		if err != nil {
+			return err
+		}
+		t4cPrintln(src + ": ... copied to " + path.Join(f))
 	}
-	return cpFile
+	return nil
+}
+
+// pick the files from a file system, like the clojure tools embedded in the binary
+func pickFSFiles(toolsDir string, fsys fs.FS, files []string) error {
+	for _, f := range files {
+		b, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			return err
+		}
+		err = saveFile(toolsFilePath(toolsDir, f), bytes.NewReader(b), int64(len(b)), 0644)
+		if err != nil {
+			return err
+		}
+		t4cPrintln(f + ": ... copied to " + path.Join(f))
+	}
+	return nil
+}
+
+// jars go to the libexec directory, everything else to the tools directory
+func toolsFilePath(toolsDir string, f string) string {
+	if strings.HasSuffix(f, ".jar") {
+		return path.Join(toolsDir, libexecDir, f)
+	}
+	return path.Join(toolsDir, f)
+}
+
+// Save exactly size bytes of r to a new file, readable by all and writable
+// only by its owner, executable only when mode is.
+func saveFile(dest string, r io.Reader, size int64, mode os.FileMode) error {
+	perm := os.FileMode(0644)
+	if mode&0111 != 0 {
+		perm = 0755
+	}
+
+	file, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	n, err := io.Copy(file, io.LimitReader(r, size+1))
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return errors.New("unexpected size of " + dest + ", expected " +
+			strconv.FormatInt(size, 10) + " bytes, got " + strconv.FormatInt(n, 10))
+	}
+
+	err = file.Sync()
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// an unpacked tarball has all files under a clojure-tools directory
+func toolsSourceDir(dir string) string {
+	if dirExists(path.Join(dir, "clojure-tools")) {
+		return path.Join(dir, "clojure-tools")
+	}
+	return dir
+}
+
+func missingFiles(dir string, files []string) []string {
+	missing := []string{}
+	for _, f := range files {
+		if !fileExists(path.Join(dir, f)) {
+			missing = append(missing, f)
+		}
+	}
+	return missing
+}
+
+func getExecCpFile(cp string, execJarPath string) string {
+	return classPathOf(cp).withEntry(execJarPath).String()
 }
@@ -13,8 +13,11 @@
 package tools4clj
 
 import (
+	"archive/tar"
+	"compress/gzip"
 	"os"
 	"path"
+	"runtime"
 	"testing"
 	"time"
 )
@@ -257,6 +260,163 @@ func TestCopyFile(t *testing.T) {
 	}
 }
 
+func TestFileSha256(t *testing.T) {
+	tmpTestFile := "test-filename.txt"
+	err := os.WriteFile(tmpTestFile, []byte("hello"), 0755)
+	if err != nil {
+		t.Errorf("Unable to write file: %v", err)
+		t.FailNow()
+	} else {
+		defer os.Remove(tmpTestFile)
+	}
+
+	expected := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	res, err := fileSha256(tmpTestFile)
+	if err != nil {
+		t.Errorf("failed to get sha256 of file: %v", err)
+	}
+	if res != expected {
+		t.Errorf("wrong sha256, expected %v, got %v", expected, res)
+	}
+
+	_, err = fileSha256("not-existing-" + tmpTestFile)
+	if err == nil {
+		t.Error("expected to get an error for not existing file")
+	}
+}
+
+// write a tarball of the given entries, the content of links is their target
+func writeTarEntries(tarPath string, entries []*tar.Header, contents []string) error {
+	out, err := os.Create(tarPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	for i, hdr := range entries {
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size = int64(len(contents[i]))
+		} else {
+			hdr.Linkname = contents[i]
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(contents[i])); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func TestPickFiles(t *testing.T) {
+	tarPath := "test-tools.tar.gz"
+	toolsDir := "test-tools-dir"
+	defer os.Remove(tarPath)
+	defer os.RemoveAll(toolsDir)
+
+	files := []string{"deps.edn", "tools.jar"}
+
+	testItems := []struct {
+		entries       []*tar.Header
+		contents      []string
+		errorExpected string
+	}{
+		{ // all files, with an executable one
+			[]*tar.Header{
+				{Name: "clojure-tools/deps.edn", Typeflag: tar.TypeReg, Mode: 0666},
+				{Name: "./clojure-tools/tools.jar", Typeflag: tar.TypeReg, Mode: 0777},
+				{Name: "clojure-tools/clojure", Typeflag: tar.TypeReg, Mode: 0755},
+			},
+			[]string{"{}", "jar", "#!/bin/sh"},
+			"",
+		},
+		{ // a missing file
+			[]*tar.Header{
+				{Name: "clojure-tools/deps.edn", Typeflag: tar.TypeReg, Mode: 0644},
+			},
+			[]string{"{}"},
+			tarPath + " is missing: tools.jar",
+		},
+		{ // path traversal
+			[]*tar.Header{
+				{Name: "clojure-tools/../../evil.sh", Typeflag: tar.TypeReg, Mode: 0755},
+			},
+			[]string{"#!/bin/sh"},
+			"unsafe path in " + tarPath + ": clojure-tools/../../evil.sh",
+		},
+		{ // absolute path
+			[]*tar.Header{
+				{Name: "/tmp/evil.sh", Typeflag: tar.TypeReg, Mode: 0755},
+			},
+			[]string{"#!/bin/sh"},
+			"unsafe path in " + tarPath + ": /tmp/evil.sh",
+		},
+		{ // a symlink
+			[]*tar.Header{
+				{Name: "clojure-tools/deps.edn", Typeflag: tar.TypeSymlink, Mode: 0777},
+			},
+			[]string{"/etc/passwd"},
+			"unexpected type of clojure-tools/deps.edn in " + tarPath + ", not a regular file",
+		},
+		{ // a hardlink
+			[]*tar.Header{
+				{Name: "clojure-tools/deps.edn", Typeflag: tar.TypeLink, Mode: 0644},
+			},
+			[]string{"/etc/passwd"},
+			"unexpected type of clojure-tools/deps.edn in " + tarPath + ", not a regular file",
+		},
+	}
+
+	for i, v := range testItems {
+		os.RemoveAll(toolsDir)
+		os.MkdirAll(path.Join(toolsDir, libexecDir), os.ModePerm)
+
+		err := writeTarEntries(tarPath, v.entries, v.contents)
+		if err != nil {
+			t.Errorf("unable to write tarball: %v", err)
+			t.FailNow()
+		}
+
+		err = pickFiles(toolsDir, tarPath, files)
+		if v.errorExpected != "" {
+			if err == nil || err.Error() != v.errorExpected {
+				t.Errorf("pick files %v, expected error `%v`, got `%v`", i, v.errorExpected, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("failed to pick files %v: %v", i, err)
+			continue
+		}
+
+		b, _ := os.ReadFile(path.Join(toolsDir, libexecDir, "tools.jar"))
+		if string(b) != "jar" {
+			t.Errorf("wrong content of picked file, got %v", string(b))
+		}
+		if fileExists(path.Join(toolsDir, "clojure")) {
+			t.Error("not requested file picked")
+		}
+		if runtime.GOOS != "windows" {
+			info, _ := os.Stat(path.Join(toolsDir, "deps.edn"))
+			if info.Mode().Perm() != 0644 {
+				t.Errorf("wrong mode of picked file, expected %v, got %v", os.FileMode(0644), info.Mode().Perm())
+			}
+			info, _ = os.Stat(path.Join(toolsDir, libexecDir, "tools.jar"))
+			if info.Mode().Perm() != 0755 {
+				t.Errorf("wrong mode of picked file, expected %v, got %v", os.FileMode(0755), info.Mode().Perm())
+			}
+		}
+	}
+}
+
 func TestCheckIsNewerFile(t *testing.T) {
 	tmpTestFile1 := "test-filename1.txt"
 	tmpTestFile2 := "test-filename2.txt"
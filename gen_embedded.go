@@ -0,0 +1,189 @@
+//go:build ignore
+
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+// Fill the embedded directory with the clojure tools files of the default
+// version, for building with the embedtools tag. Run by
+//
+//	go generate -tags embedtools
+//
+// it downloads the released tarball (or reads the one given as argument),
+// verifies it against the known digest of tools_sha256.go, extracts its
+// files, and records their version in embedded_version.go, so that a stale
+// embed, of another version, fails the build.
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const embeddedDir = "embedded"
+
+var (
+	versionRe = regexp.MustCompile(`(?m)^\s*version\s+=\s+"([0-9.]+)"`)
+	entryRe   = regexp.MustCompile(`"([0-9.]+)":\s+"([0-9a-f]{64})"`)
+)
+
+func main() {
+	err := run(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gen_embedded: "+err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	src, err := os.ReadFile("clojure_tools.go")
+	if err != nil {
+		return err
+	}
+	m := versionRe.FindSubmatch(src)
+	if m == nil {
+		return errors.New("version not found in clojure_tools.go")
+	}
+	version := string(m[1])
+
+	b, err := os.ReadFile("tools_sha256.go")
+	if err != nil {
+		return err
+	}
+	digest := ""
+	for _, entry := range entryRe.FindAllStringSubmatch(string(b), -1) {
+		if entry[1] == version {
+			digest = entry[2]
+		}
+	}
+	if digest == "" {
+		return errors.New("no known sha256 digest of " + version + " in tools_sha256.go, record it with: go generate")
+	}
+
+	tarGz := "clojure-tools-" + version + ".tar.gz"
+	var tarball []byte
+	if len(args) > 0 {
+		tarball, err = os.ReadFile(args[0])
+	} else {
+		tarball, err = fetch("https://github.com/clojure/brew-install/releases/download/" + version + "/" + tarGz)
+	}
+	if err != nil {
+		return err
+	}
+	h := sha256.Sum256(tarball)
+	if actual := hex.EncodeToString(h[:]); actual != digest {
+		return errors.New("sha256 mismatch for " + tarGz + ", expected " + digest + ", got " + actual)
+	}
+
+	files := []string{"deps.edn", "example-deps.edn", "tools.edn", "exec.jar", "clojure-tools-" + version + ".jar"}
+	err = os.RemoveAll(embeddedDir)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(embeddedDir, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	err = extract(tarball, files)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("gen_embedded: embedded clojure tools " + version)
+	return writeVersion(version, digest)
+}
+
+func fetch(url string) ([]byte, error) {
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(url + ": " + resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// extract the files, from the clojure-tools directory of the tarball
+func extract(tarball []byte, files []string) error {
+	gz, err := gzip.NewReader(strings.NewReader(string(tarball)))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	missing := map[string]bool{}
+	for _, f := range files {
+		missing[f] = true
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		f := strings.TrimPrefix(path.Clean(hdr.Name), "clojure-tools/")
+		if !missing[f] || hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		out, err := os.Create(path.Join(embeddedDir, f))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, tr)
+		out.Close()
+		if err != nil {
+			return err
+		}
+		delete(missing, f)
+	}
+	if len(missing) > 0 {
+		names := []string{}
+		for f := range missing {
+			names = append(names, f)
+		}
+		return errors.New("tarball is missing: " + strings.Join(names, ", "))
+	}
+	return nil
+}
+
+func writeVersion(version string, digest string) error {
+	header, err := os.ReadFile("clojure_tools.go")
+	if err != nil {
+		return err
+	}
+	text := "//go:build embedtools\n\n"
+	text += string(header[:strings.Index(string(header), "package ")])
+	text += "// Code generated by gen_embedded.go; DO NOT EDIT.\n\n"
+	text += "package tools4clj\n\n"
+	text += "// the version, and tarball digest, of the embedded clojure tools\n"
+	text += "const (\n"
+	text += "\tembeddedVersion = \"" + version + "\"\n"
+	text += "\tembeddedSha256  = \"" + digest + "\"\n"
+	text += ")\n"
+	return os.WriteFile("embedded_version.go", []byte(text), 0644)
+}
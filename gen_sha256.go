@@ -0,0 +1,145 @@
+//go:build ignore
+
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+// Record the sha256 digest of the clojure tools tarball, of the version
+// tools4clj is based on, in tools_sha256.go. Run by go generate, when
+// bumping the version, it downloads the released tarball and checks it
+// against the digest published on download.clojure.org.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const tableFile = "tools_sha256.go"
+
+var (
+	versionRe = regexp.MustCompile(`(?m)^\s*version\s+=\s+"([0-9.]+)"`)
+	entryRe   = regexp.MustCompile(`"([0-9.]+)":\s+"([0-9a-f]{64})"`)
+)
+
+var client = &http.Client{Timeout: 5 * time.Minute}
+
+func main() {
+	err := run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gen_sha256: "+err.Error())
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	src, err := os.ReadFile("clojure_tools.go")
+	if err != nil {
+		return err
+	}
+	m := versionRe.FindSubmatch(src)
+	if m == nil {
+		return errors.New("version not found in clojure_tools.go")
+	}
+	version := string(m[1])
+
+	table := map[string]string{}
+	b, err := os.ReadFile(tableFile)
+	if err == nil {
+		for _, entry := range entryRe.FindAllStringSubmatch(string(b), -1) {
+			table[entry[1]] = entry[2]
+		}
+	}
+	if _, found := table[version]; found {
+		fmt.Println("gen_sha256: " + version + " already recorded")
+		return nil
+	}
+
+	tarGz := "clojure-tools-" + version + ".tar.gz"
+	digest, err := tarballSha256("https://github.com/clojure/brew-install/releases/download/" + version + "/" + tarGz)
+	if err != nil {
+		return err
+	}
+	published, err := fetch("https://download.clojure.org/install/" + tarGz + ".sha256")
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(published))
+	if len(fields) == 0 || !strings.EqualFold(fields[0], digest) {
+		return errors.New("sha256 of the released " + tarGz + " " + digest + " does not match the published one")
+	}
+
+	table[version] = digest
+	fmt.Println("gen_sha256: recorded " + version + " " + digest)
+	return writeTable(table)
+}
+
+func fetch(url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(url + ": " + resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func tarballSha256(url string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New(url + ": " + resp.Status)
+	}
+	h := sha256.New()
+	_, err = io.Copy(h, resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func writeTable(table map[string]string) error {
+	versions := []string{}
+	for v := range table {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+
+	header, err := os.ReadFile("clojure_tools.go")
+	if err != nil {
+		return err
+	}
+	text := string(header[:strings.Index(string(header), "package ")])
+	text += "// Code generated by gen_sha256.go; DO NOT EDIT.\n\n"
+	text += "package tools4clj\n\n"
+	text += "// Known sha256 digests of the official clojure tools tarballs, by version.\n"
+	text += "// They are trusted over the .sha256 files, next to the tarballs.\n"
+	text += "var toolsSha256 = map[string]string{\n"
+	for _, v := range versions {
+		text += "\t\"" + v + "\": \"" + table[v] + "\",\n"
+	}
+	text += "}\n"
+	return os.WriteFile(tableFile, []byte(text), 0644)
+}
@@ -15,6 +15,8 @@ package tools4clj
 import (
 	"bufio"
 	"os"
+	"strconv"
+	"time"
 )
 
 func join(items []string, char string) string {
@@ -57,3 +59,27 @@ func readNonEmptyLines(path string) ([]string, error) {
 	}
 	return lines, scanner.Err()
 }
+
+// an environment variable is true when set, but not to "", "0" or "false"
+func envTrue(name string) bool {
+	env, found := os.LookupEnv(name)
+	return found && env != "" && env != "0" && env != "false"
+}
+
+// read a duration from an environment variable, either
+// as a go duration (e.g. "90s", "5m") or as seconds
+func envDuration(name string, defaultValue time.Duration) time.Duration {
+	env, found := os.LookupEnv(name)
+	if !found {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(env)
+	if err == nil && d > 0 {
+		return d
+	}
+	secs, err := strconv.Atoi(env)
+	if err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	return defaultValue
+}
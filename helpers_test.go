@@ -18,6 +18,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 type TestJoinItem struct {
@@ -121,3 +122,59 @@ func TestReadLines(t *testing.T) {
 		t.Errorf("readlines error, reading non existing file")
 	}
 }
+
+func TestEnvTrue(t *testing.T) {
+	envName := "T4C_TEST_FLAG"
+	defer os.Unsetenv(envName)
+
+	testItems := map[string]bool{
+		"1":     true,
+		"true":  true,
+		"yes":   true,
+		"":      false,
+		"0":     false,
+		"false": false,
+	}
+
+	for value, expected := range testItems {
+		os.Setenv(envName, value)
+		if envTrue(envName) != expected {
+			t.Errorf("envTrue of %v failed, expected %v", value, expected)
+		}
+	}
+
+	os.Unsetenv(envName)
+	if envTrue(envName) {
+		t.Error("envTrue of not set variable failed")
+	}
+}
+
+func TestEnvDuration(t *testing.T) {
+	envName := "T4C_TEST_DURATION"
+	defer os.Unsetenv(envName)
+
+	testItems := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"90s", 90 * time.Second},
+		{"5m", 5 * time.Minute},
+		{"30", 30 * time.Second},
+		{"invalid", time.Minute},
+		{"-5", time.Minute},
+	}
+
+	for _, v := range testItems {
+		os.Setenv(envName, v.value)
+		res := envDuration(envName, time.Minute)
+		if res != v.expected {
+			t.Errorf("envDuration of %v failed, expected %v, got %v", v.value, v.expected, res)
+		}
+	}
+
+	os.Unsetenv(envName)
+	res := envDuration(envName, time.Minute)
+	if res != time.Minute {
+		t.Errorf("envDuration default failed, expected %v, got %v", time.Minute, res)
+	}
+}
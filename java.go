@@ -0,0 +1,343 @@
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// the oldest java supported by clojure tools
+	minJavaMajor       = 8
+	javaVersionFile    = ".java-version"
+	javaCacheDir       = "java-cache"
+	javaVersionTimeout = 30 * time.Second
+)
+
+// a java runtime, as reported by its java -version
+type javaInfo struct {
+	path    string
+	version string
+	vendor  string
+	major   int
+}
+
+// The java executable, set by JAVA_CMD, or found in JAVA_HOME,
+// or else the first one in PATH.
+func getJavaPath() (string, error) {
+	env, found := os.LookupEnv("JAVA_CMD")
+	if found && env != "" {
+		return env, nil
+	}
+
+	env, found = os.LookupEnv("JAVA_HOME")
+	if found && env != "" {
+		p := path.Join(env, "bin", javaExecutable())
+		if !fileExists(p) {
+			return "", errors.New("JAVA_HOME is set to " + env + ", but " + p +
+				" does not exist - please set JAVA_HOME to a java installation")
+		}
+		return p, nil
+	}
+
+	p, err := exec.LookPath("java")
+	if err != nil {
+		return "", errors.New("could not find java - please install java " +
+			strconv.Itoa(minJavaMajor) + " or newer, or set JAVA_HOME")
+	}
+	return p, nil
+}
+
+// The java of the project, of the :t4c/java-home setting, or of the
+// version in a .java-version file, found in the common JDK install roots;
+// falls back to getJavaPath. JAVA_CMD still overrides the project JDK.
+func getProjectJavaPath() (string, error) {
+	env, found := os.LookupEnv("JAVA_CMD")
+	if found && env != "" {
+		return env, nil
+	}
+
+	javaHome := settings.value(":t4c/java-home")
+	if javaHome != "" {
+		p := path.Join(javaHome, "bin", javaExecutable())
+		if !fileExists(p) {
+			return "", errors.New(":t4c/java-home of " + settingSource(":t4c/java-home") + " is set to " + javaHome +
+				", but " + p + " does not exist")
+		}
+		return p, nil
+	}
+
+	if fileExists(javaVersionFile) {
+		lines, err := readNonEmptyLines(javaVersionFile)
+		if err != nil {
+			return "", err
+		}
+		if len(lines) > 0 {
+			v := strings.TrimSpace(lines[0])
+			javaHome := findJavaHome(v, javaInstallRoots())
+			if javaHome == "" {
+				return "", errors.New("java " + v + " of " + javaVersionFile + " is not installed in " +
+					join(javaInstallRoots(), ", ") + " - please install it, or set :t4c/java-home in " + projectT4CEDN)
+			}
+			return path.Join(javaHome, "bin", javaExecutable()), nil
+		}
+	}
+
+	return getJavaPath()
+}
+
+// the directories holding JDKs, of SDKMAN, asdf, and the system ones
+func javaInstallRoots() []string {
+	home, _ := os.UserHomeDir()
+
+	sdkman := os.Getenv("SDKMAN_DIR")
+	if sdkman == "" {
+		sdkman = path.Join(home, ".sdkman")
+	}
+	asdf := os.Getenv("ASDF_DATA_DIR")
+	if asdf == "" {
+		asdf = path.Join(home, ".asdf")
+	}
+
+	roots := []string{
+		path.Join(sdkman, "candidates", "java"),
+		path.Join(asdf, "installs", "java"),
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		roots = append(roots, "/Library/Java/JavaVirtualMachines")
+	case "windows":
+	default:
+		roots = append(roots, "/usr/lib/jvm")
+	}
+	return roots
+}
+
+// a version at the start of a name, or after a dash, or jdk (as in jdk1.8.0_381)
+var javaDirVersion = regexp.MustCompile(`(?:^|[-_@]|jdk)([0-9]+(?:\.[0-9]+)*)`)
+
+// Find the home of a JDK in the install roots, named exactly as the
+// requested version (e.g. 17.0.8-tem), or else the newest one of it
+// (e.g. 17, of java-17-openjdk-amd64, or temurin-17.0.8+7).
+func findJavaHome(requested string, roots []string) string {
+	requestedVersion := javaVersionOf(requested)
+
+	var found, foundVersion string
+	for _, root := range roots {
+		home := javaHomeOf(path.Join(root, requested))
+		if home != "" {
+			return home
+		}
+		if requestedVersion == "" {
+			continue
+		}
+
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			v := javaVersionOf(entry.Name())
+			if v != requestedVersion && !strings.HasPrefix(v, requestedVersion+".") {
+				continue
+			}
+			home := javaHomeOf(path.Join(root, entry.Name()))
+			if home != "" && (found == "" || compareVersions(v, foundVersion) > 0) {
+				found, foundVersion = home, v
+			}
+		}
+	}
+	return found
+}
+
+// the version in a JDK name, where 1.8.0 and 8.0 are both java 8
+func javaVersionOf(name string) string {
+	m := javaDirVersion.FindStringSubmatch(name)
+	if m == nil {
+		return ""
+	}
+	return strings.TrimPrefix(m[1], "1.")
+}
+
+// the java home of a JDK directory, also of a macOS bundle one
+func javaHomeOf(dir string) string {
+	for _, home := range []string{dir, path.Join(dir, "Contents", "Home")} {
+		if fileExists(path.Join(home, "bin", javaExecutable())) {
+			return home
+		}
+	}
+	return ""
+}
+
+func javaExecutable() string {
+	if runtime.GOOS == "windows" {
+		return "java.exe"
+	}
+	return "java"
+}
+
+// Detect the version and vendor of a java executable, running its
+// java -version once, cached for as long as the binary is not modified.
+func detectJava(javaPath string) (javaInfo, error) {
+	binary, err := exec.LookPath(javaPath)
+	if err != nil {
+		return javaInfo{}, errors.New("could not find java " + javaPath + ": " + err.Error())
+	}
+	// the actual binary, behind any alternatives links
+	resolved, err := filepath.EvalSymlinks(binary)
+	if err == nil {
+		binary = resolved
+	}
+	stat, err := os.Stat(binary)
+	if err != nil {
+		return javaInfo{}, err
+	}
+
+	cacheFile := javaCacheFile(binary)
+	info, found := readJavaCache(cacheFile, binary, stat.ModTime())
+	if !found {
+		ctx, cancel := context.WithTimeout(context.Background(), javaVersionTimeout)
+		defer cancel()
+
+		out, err := exec.CommandContext(ctx, binary, "-XshowSettings:properties", "-version").CombinedOutput()
+		if err != nil {
+			return javaInfo{}, errors.New("could not run " + javaPath + " -version: " + err.Error())
+		}
+		info, err = parseJavaVersion(string(out))
+		if err != nil {
+			return javaInfo{}, errors.New("could not detect the version of " + javaPath + ": " + err.Error())
+		}
+		writeJavaCache(cacheFile, binary, stat.ModTime(), info)
+	}
+
+	info.path = javaPath
+	return info, nil
+}
+
+// the version and vendor, e.g. 17.0.8 (Eclipse Adoptium)
+func (j javaInfo) describe() string {
+	if j.vendor == "" {
+		return j.version
+	}
+	return j.version + " (" + j.vendor + ")"
+}
+
+// fail early when java is too old for clojure tools
+func checkJavaVersion(info javaInfo) error {
+	if info.major < minJavaMajor {
+		return errors.New("java " + info.version + " (" + info.path + ") is too old, clojure tools need java " +
+			strconv.Itoa(minJavaMajor) + " or newer - please install a newer java, and set JAVA_HOME or JAVA_CMD to it")
+	}
+	return nil
+}
+
+var javaVersionLine = regexp.MustCompile(`version "([^"]+)"`)
+
+// Parse the output of java -XshowSettings:properties -version, or
+// when the properties are missing, the version line of java -version.
+func parseJavaVersion(out string) (javaInfo, error) {
+	info := javaInfo{}
+	for _, line := range strings.Split(out, "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), " = ")
+		if !found {
+			continue
+		}
+		switch key {
+		case "java.version":
+			info.version = value
+		case "java.vendor":
+			info.vendor = value
+		}
+	}
+
+	if info.version == "" {
+		m := javaVersionLine.FindStringSubmatch(out)
+		if m == nil {
+			return javaInfo{}, errors.New("unexpected output: " + firstLine(out))
+		}
+		info.version = m[1]
+	}
+
+	info.major = javaMajor(info.version)
+	if info.major == 0 {
+		return javaInfo{}, errors.New("unexpected version: " + info.version)
+	}
+	return info, nil
+}
+
+// the major version, of both 1.8.0_381 and 17.0.8 version styles
+func javaMajor(version string) int {
+	v := strings.TrimPrefix(version, "1.")
+	end := 0
+	for end < len(v) && v[end] >= '0' && v[end] <= '9' {
+		end++
+	}
+	major, _ := strconv.Atoi(v[:end])
+	return major
+}
+
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return line
+}
+
+// the cache file of a java binary, under ~/.tools4clj/java-cache
+func javaCacheFile(binary string) string {
+	t4cDir, err := getT4CHomePath()
+	if err != nil {
+		return ""
+	}
+	h := sha256.Sum256([]byte(binary))
+	return path.Join(t4cDir, javaCacheDir, hex.EncodeToString(h[:8]))
+}
+
+// the cache holds the binary path, its modification time, version and vendor
+func readJavaCache(cacheFile string, binary string, modTime time.Time) (javaInfo, bool) {
+	if cacheFile == "" {
+		return javaInfo{}, false
+	}
+	b, err := os.ReadFile(cacheFile)
+	if err != nil {
+		return javaInfo{}, false
+	}
+	lines := strings.Split(string(b), "\n")
+	if len(lines) < 4 || lines[0] != binary || lines[1] != strconv.FormatInt(modTime.UnixNano(), 10) {
+		return javaInfo{}, false
+	}
+	info := javaInfo{version: lines[2], vendor: lines[3], major: javaMajor(lines[2])}
+	return info, info.major > 0
+}
+
+func writeJavaCache(cacheFile string, binary string, modTime time.Time, info javaInfo) {
+	if cacheFile == "" {
+		return
+	}
+	err := os.MkdirAll(path.Dir(cacheFile), os.ModePerm)
+	if err != nil {
+		return
+	}
+	os.WriteFile(cacheFile, []byte(binary+"\n"+strconv.FormatInt(modTime.UnixNano(), 10)+"\n"+
+		info.version+"\n"+info.vendor+"\n"), 0644)
+}
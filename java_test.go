@@ -0,0 +1,328 @@
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseJavaVersion(t *testing.T) {
+	testItems := []struct {
+		out      string
+		expected javaInfo
+	}{
+		{ // properties
+			"Property settings:\n    java.class.version = 61.0\n    java.vendor = Eclipse Adoptium\n" +
+				"    java.version = 17.0.8\n\nopenjdk version \"17.0.8\" 2023-07-18\n",
+			javaInfo{version: "17.0.8", vendor: "Eclipse Adoptium", major: 17},
+		},
+		{ // java 8 version line
+			"java version \"1.8.0_381\"\nJava(TM) SE Runtime Environment (build 1.8.0_381-b09)\n",
+			javaInfo{version: "1.8.0_381", major: 8},
+		},
+		{ // early access
+			"openjdk version \"24-ea\" 2025-03-18\n",
+			javaInfo{version: "24-ea", major: 24},
+		},
+	}
+
+	for _, v := range testItems {
+		res, err := parseJavaVersion(v.out)
+		if err != nil {
+			t.Errorf("failed to parse java version of %v: %v", v.out, err)
+		}
+		if res != v.expected {
+			t.Errorf("wrong java version, expected %+v, got %+v", v.expected, res)
+		}
+	}
+
+	_, err := parseJavaVersion("Error: could not create the Java Virtual Machine.")
+	if err == nil {
+		t.Error("expected to get an error for unexpected java -version output")
+	}
+}
+
+func TestJavaMajor(t *testing.T) {
+	testItems := map[string]int{
+		"1.8.0_381": 8,
+		"1.7.0":     7,
+		"11.0.2":    11,
+		"21":        21,
+		"22-ea":     22,
+		"":          0,
+	}
+
+	for v, expected := range testItems {
+		if javaMajor(v) != expected {
+			t.Errorf("wrong java major of %v, expected %v, got %v", v, expected, javaMajor(v))
+		}
+	}
+}
+
+func TestCheckJavaVersion(t *testing.T) {
+	err := checkJavaVersion(javaInfo{path: "java", version: "1.7.0_80", major: 7})
+	if err == nil || !strings.Contains(err.Error(), "too old") {
+		t.Errorf("expected to get a too old error, got %v", err)
+	}
+	err = checkJavaVersion(javaInfo{path: "java", version: "1.8.0_381", major: 8})
+	if err != nil {
+		t.Errorf("failed to check java version: %v", err)
+	}
+}
+
+// a java executable printing the given version, and counting its runs
+func writeFakeJava(javaHome string, version string) (string, error) {
+	err := os.MkdirAll(path.Join(javaHome, "bin"), os.ModePerm)
+	if err != nil {
+		return "", err
+	}
+	java := path.Join(javaHome, "bin", "java")
+	script := "#!/bin/sh\necho run >> \"$(dirname \"$0\")/runs\"\n" +
+		"echo '    java.vendor = Test Vendor' >&2\necho '    java.version = " + version + "' >&2\n"
+	return java, os.WriteFile(java, []byte(script), 0755)
+}
+
+func TestGetJavaPathOrder(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake java is a shell script")
+	}
+	for _, name := range []string{"JAVA_CMD", "JAVA_HOME", "PATH"} {
+		env, found := os.LookupEnv(name)
+		if found {
+			defer os.Setenv(name, env)
+		} else {
+			defer os.Unsetenv(name)
+		}
+	}
+
+	javaHome := "test-java-home"
+	pathHome := "test-path-java-home"
+	defer os.RemoveAll(javaHome)
+	defer os.RemoveAll(pathHome)
+
+	homeJava, _ := writeFakeJava(javaHome, "17.0.8")
+	pathJava, _ := writeFakeJava(pathHome, "11.0.2")
+	pathDir, _ := filepath.Abs(path.Dir(pathJava))
+
+	// from PATH
+	os.Unsetenv("JAVA_CMD")
+	os.Unsetenv("JAVA_HOME")
+	os.Setenv("PATH", pathDir)
+	res, err := getJavaPath()
+	if err != nil || res != path.Join(pathDir, "java") {
+		t.Errorf("wrong java path from PATH, got %v, error %v", res, err)
+	}
+
+	// JAVA_HOME before PATH
+	os.Setenv("JAVA_HOME", javaHome)
+	res, err = getJavaPath()
+	if err != nil || res != homeJava {
+		t.Errorf("wrong java path from JAVA_HOME, expected %v, got %v, error %v", homeJava, res, err)
+	}
+
+	// JAVA_CMD before all
+	os.Setenv("JAVA_CMD", "my-java")
+	res, err = getJavaPath()
+	if err != nil || res != "my-java" {
+		t.Errorf("wrong java path from JAVA_CMD, got %v, error %v", res, err)
+	}
+
+	// JAVA_HOME without java
+	os.Unsetenv("JAVA_CMD")
+	os.Setenv("JAVA_HOME", "not-existing-"+javaHome)
+	_, err = getJavaPath()
+	if err == nil || !strings.Contains(err.Error(), "JAVA_HOME") {
+		t.Errorf("expected to get a JAVA_HOME error, got %v", err)
+	}
+
+	// no java at all
+	os.Unsetenv("JAVA_HOME")
+	os.Setenv("PATH", "")
+	_, err = getJavaPath()
+	if err == nil {
+		t.Error("expected to get an error for missing java")
+	}
+}
+
+func TestDetectJava(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake java is a shell script")
+	}
+	envHome, found := os.LookupEnv("HOME")
+	if found {
+		defer os.Setenv("HOME", envHome)
+	}
+
+	home, _ := filepath.Abs("test-home")
+	javaHome := "test-java-home"
+	defer os.RemoveAll(home)
+	defer os.RemoveAll(javaHome)
+	os.Setenv("HOME", home)
+
+	java, err := writeFakeJava(javaHome, "17.0.8")
+	if err != nil {
+		t.Errorf("unable to write fake java: %v", err)
+		t.FailNow()
+	}
+
+	expected := javaInfo{path: java, version: "17.0.8", vendor: "Test Vendor", major: 17}
+	for i := 0; i < 2; i++ {
+		res, err := detectJava(java)
+		if err != nil {
+			t.Errorf("failed to detect java: %v", err)
+		}
+		if res != expected {
+			t.Errorf("wrong detected java, expected %+v, got %+v", expected, res)
+		}
+	}
+
+	// java -version is run once, and then cached
+	runs, _ := readNonEmptyLines(path.Join(javaHome, "bin", "runs"))
+	if len(runs) != 1 {
+		t.Errorf("java -version run %v times, expected once", len(runs))
+	}
+
+	// a modified binary is detected again
+	writeFakeJava(javaHome, "21.0.1")
+	os.Chtimes(java, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	res, _ := detectJava(java)
+	if res.major != 21 {
+		t.Errorf("modified java not detected again, got %+v", res)
+	}
+
+	_, err = detectJava("not-existing-java")
+	if err == nil {
+		t.Error("expected to get an error for not existing java")
+	}
+}
+
+func TestJavaVersionOf(t *testing.T) {
+	testItems := map[string]string{
+		"17.0.8-tem":            "17.0.8",
+		"temurin-17.0.8+7":      "17.0.8",
+		"temurin64-17":          "17",
+		"java-17-openjdk-amd64": "17",
+		"java-1.8.0-openjdk":    "8.0",
+		"jdk1.8.0_381.jdk":      "8.0",
+		"zulu-21.jdk":           "21",
+		"default-java":          "",
+	}
+
+	for name, expected := range testItems {
+		if javaVersionOf(name) != expected {
+			t.Errorf("wrong java version of %v, expected %v, got %v", name, expected, javaVersionOf(name))
+		}
+	}
+}
+
+// JDK homes with a java executable, under root
+func writeJavaHomes(root string, names []string) {
+	for _, name := range names {
+		os.MkdirAll(path.Join(root, name, "bin"), os.ModePerm)
+		os.WriteFile(path.Join(root, name, "bin", javaExecutable()), []byte{}, 0755)
+	}
+}
+
+func TestFindJavaHome(t *testing.T) {
+	sdkman := "test-sdkman-java"
+	jvm := "test-jvm"
+	defer os.RemoveAll(sdkman)
+	defer os.RemoveAll(jvm)
+
+	writeJavaHomes(sdkman, []string{"17.0.8-tem", "17.0.10-tem", "21.0.1-open"})
+	writeJavaHomes(jvm, []string{"java-11-openjdk-amd64", "java-1.8.0-openjdk", "java-21-openjdk-amd64"})
+	// not a JDK
+	os.MkdirAll(path.Join(jvm, "java-22-openjdk-amd64"), os.ModePerm)
+	roots := []string{sdkman, jvm}
+
+	testItems := map[string]string{
+		"17.0.8-tem": path.Join(sdkman, "17.0.8-tem"),
+		"17":         path.Join(sdkman, "17.0.10-tem"),
+		"21":         path.Join(sdkman, "21.0.1-open"),
+		"11":         path.Join(jvm, "java-11-openjdk-amd64"),
+		"1.8":        path.Join(jvm, "java-1.8.0-openjdk"),
+		"8":          path.Join(jvm, "java-1.8.0-openjdk"),
+		"22":         "",
+		"1":          "",
+	}
+
+	for requested, expected := range testItems {
+		res := findJavaHome(requested, roots)
+		if res != expected {
+			t.Errorf("wrong java home of %v, expected %v, got %v", requested, expected, res)
+		}
+	}
+}
+
+func TestGetProjectJavaPath(t *testing.T) {
+	for _, name := range []string{"JAVA_CMD", "SDKMAN_DIR"} {
+		env, found := os.LookupEnv(name)
+		if found {
+			defer os.Setenv(name, env)
+		} else {
+			defer os.Unsetenv(name)
+		}
+	}
+	defer func() { settings = t4cSettings{} }()
+	defer os.Remove(javaVersionFile)
+
+	sdkman := "test-sdkman"
+	defer os.RemoveAll(sdkman)
+	writeJavaHomes(path.Join(sdkman, "candidates", "java"), []string{"17.0.8-tem", "21.0.1-tem"})
+	os.Setenv("SDKMAN_DIR", sdkman)
+	os.Unsetenv("JAVA_CMD")
+
+	// of .java-version
+	os.WriteFile(javaVersionFile, []byte("21\n"), 0644)
+	expected := path.Join(sdkman, "candidates", "java", "21.0.1-tem", "bin", javaExecutable())
+	res, err := getProjectJavaPath()
+	if err != nil || res != expected {
+		t.Errorf("wrong .java-version java path, expected %v, got %v, error %v", expected, res, err)
+	}
+
+	// not installed
+	os.WriteFile(javaVersionFile, []byte("19"), 0644)
+	_, err = getProjectJavaPath()
+	if err == nil || !strings.Contains(err.Error(), javaVersionFile) {
+		t.Errorf("expected to get a not installed error, got %v", err)
+	}
+
+	// :t4c/java-home overrides .java-version
+	javaHome := path.Join(sdkman, "candidates", "java", "17.0.8-tem")
+	settings = t4cSettings{":t4c/java-home": []string{javaHome}}
+	res, err = getProjectJavaPath()
+	if err != nil || res != path.Join(javaHome, "bin", javaExecutable()) {
+		t.Errorf("wrong :t4c/java-home java path, got %v, error %v", res, err)
+	}
+
+	settings = t4cSettings{":t4c/java-home": []string{"not-existing-" + javaHome}}
+	settingsSources = map[string]string{":t4c/java-home": "/home/user/.tools4clj/config.edn"}
+	defer func() { settingsSources = map[string]string{} }()
+	_, err = getProjectJavaPath()
+	if err == nil || !strings.HasPrefix(err.Error(), ":t4c/java-home of /home/user/.tools4clj/config.edn is set to") {
+		t.Errorf("expected to get an error for not existing :t4c/java-home, naming its file, got %v", err)
+	}
+
+	// JAVA_CMD overrides the project java
+	os.Setenv("JAVA_CMD", "my-java")
+	res, err = getProjectJavaPath()
+	if err != nil || res != "my-java" {
+		t.Errorf("wrong java path from JAVA_CMD, got %v, error %v", res, err)
+	}
+}
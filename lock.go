@@ -0,0 +1,109 @@
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+const lockRetryInterval = 100 * time.Millisecond
+
+// an advisory, inter-process, lock on a file
+type fileLock struct {
+	file *os.File
+	path string
+}
+
+// Acquire the lock, waiting up to timeout for other processes to release it.
+// The wait message is shown once, when the lock is held by another process.
+func acquireLock(lockPath string, timeout time.Duration, waitMessage string) (*fileLock, error) {
+	deadline := time.Now().Add(timeout)
+	waiting := false
+	for {
+		lock, err := tryAcquireLock(lockPath)
+		if err != nil {
+			return nil, err
+		}
+		if lock != nil {
+			return lock, nil
+		}
+
+		if !waiting {
+			t4cPrintln(waitMessage)
+			waiting = true
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.New("timed out after " + timeout.String() + " waiting for lock " + lockPath)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// the lock file is kept, unless removed by its holder, with remove()
+func (l *fileLock) release() error {
+	err := unlockFile(l.file)
+	if err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
+
+// Remove the lock file, along with what it guards, and release it.
+// Processes waiting on the removed file find out, once they lock it.
+func (l *fileLock) remove() error {
+	err := os.Remove(l.path)
+	releaseErr := l.release()
+	if err != nil && !os.IsNotExist(err) {
+		// open files can not be removed on windows
+		err = os.Remove(l.path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return releaseErr
+}
+
+// Acquire the lock only when free, without waiting.
+// It returns a nil lock, when held by another process.
+func tryAcquireLock(lockPath string) (*fileLock, error) {
+	file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	locked, err := tryLockFile(file)
+	if err != nil || !locked {
+		file.Close()
+		return nil, err
+	}
+
+	// removed, by its previous holder, while locking it
+	if !isFileAt(file, lockPath) {
+		unlockFile(file)
+		file.Close()
+		return nil, nil
+	}
+	return &fileLock{file: file, path: lockPath}, nil
+}
+
+func isFileAt(file *os.File, filePath string) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	pathInfo, err := os.Stat(filePath)
+	return err == nil && os.SameFile(info, pathInfo)
+}
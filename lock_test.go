@@ -0,0 +1,115 @@
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestAcquireLock(t *testing.T) {
+	lockPath := "test-file.lock"
+	defer os.Remove(lockPath)
+
+	lock, err := acquireLock(lockPath, time.Second, "waiting for test lock")
+	if err != nil {
+		t.Errorf("failed to acquire lock: %v", err)
+		t.FailNow()
+	}
+
+	// already held
+	_, err = acquireLock(lockPath, 300*time.Millisecond, "waiting for test lock")
+	if err == nil {
+		t.Error("expected to get an error when lock is held")
+	}
+
+	// released while waiting
+	held := lock
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		held.release()
+	}()
+	released, err := acquireLock(lockPath, 5*time.Second, "waiting for test lock")
+	if err != nil {
+		t.Errorf("failed to acquire released lock: %v", err)
+		t.FailNow()
+	}
+
+	err = released.release()
+	if err != nil {
+		t.Errorf("failed to release lock: %v", err)
+	}
+}
+
+func TestRemoveLock(t *testing.T) {
+	lockPath := "test-file.lock"
+	defer os.Remove(lockPath)
+
+	lock, err := acquireLock(lockPath, time.Second, "")
+	if err != nil {
+		t.Errorf("failed to acquire lock: %v", err)
+		t.FailNow()
+	}
+
+	// a waiting process opened the lock file, before it was removed
+	waiting, _ := os.OpenFile(lockPath, os.O_RDWR, 0644)
+	defer waiting.Close()
+
+	err = lock.remove()
+	if err != nil || fileExists(lockPath) {
+		t.Errorf("failed to remove lock: %v", err)
+	}
+	locked, _ := tryLockFile(waiting)
+	if locked && isFileAt(waiting, lockPath) {
+		t.Error("expected the removed lock file not to be at its path")
+	}
+	if locked {
+		unlockFile(waiting)
+	}
+
+	other, err := tryAcquireLock(lockPath)
+	if err != nil || other == nil {
+		t.Errorf("failed to acquire lock after removal: %v", err)
+		t.FailNow()
+	}
+	other.release()
+}
+
+func TestTryAcquireLock(t *testing.T) {
+	lockPath := "test-file.lock"
+	defer os.Remove(lockPath)
+
+	lock, err := tryAcquireLock(lockPath)
+	if err != nil || lock == nil {
+		t.Errorf("failed to acquire free lock: %v", err)
+		t.FailNow()
+	}
+
+	other, err := tryAcquireLock(lockPath)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if other != nil {
+		t.Error("acquired held lock")
+		other.release()
+	}
+
+	lock.release()
+	other, err = tryAcquireLock(lockPath)
+	if err != nil || other == nil {
+		t.Errorf("failed to acquire released lock: %v", err)
+		t.FailNow()
+	}
+	other.release()
+}
@@ -13,27 +13,42 @@
 package tools4clj
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"hash/crc32"
 	"io/ioutil"
 	"os"
 	"path"
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type allOpts struct {
 	Clj        cljOpts
 	Init       initOpts
 	Main       mainOpts
+	T4C        t4cOpts
 	Args       []string
 	NativeArgs bool
 	Rlwrap     bool
 	Mode       string
 }
 
+type t4cOpts struct {
+	InstallFrom  string
+	Quiet        bool
+	ToolsVersion string
+	Versions     bool
+	GC           bool
+	Keep         int
+	CheckUpdate  bool
+	Cache        string
+	OlderThan    time.Duration
+}
+
 type cljOpts struct {
 	JvmOpts        []string
 	MainAliases    string
@@ -50,9 +65,11 @@ type cljOpts struct {
 	Tree           bool
 	Force          bool
 	Verbose        bool
+	Explain        bool
 	Describe       bool
 	Threads        int
 	Trace          bool
+	CacheDir       string
 	InvalidOption  string
 }
 
@@ -147,6 +164,76 @@ out:
 
 		case "--native-args":
 			all.NativeArgs = true
+		case "--t4c-quiet":
+			all.T4C.Quiet = true
+		case "--t4c-tools-version":
+			if len(all.T4C.ToolsVersion) > 0 {
+				return pos, errors.New("tools version option " + args[pos] + " defined more than one time")
+			}
+			if pos+1 > len(args)-1 {
+				return pos, errors.New("tools version not defined for " + args[pos] + " option")
+			}
+			pos++
+			if !isValidToolsVersion(args[pos]) {
+				return pos, errors.New("invalid clojure tools version: " + args[pos])
+			}
+			all.T4C.ToolsVersion = args[pos]
+		case "--t4c-versions":
+			all.T4C.Versions = true
+		case "--t4c-gc":
+			all.T4C.GC = true
+		case "--keep":
+			if !all.T4C.GC {
+				return pos, errors.New("option " + args[pos] + " can only be used with --t4c-gc")
+			}
+			if pos+1 > len(args)-1 {
+				return pos, errors.New("keep value (N) not defined for " + args[pos] + " option")
+			}
+			pos++
+			i, err := strconv.Atoi(args[pos])
+			if err != nil || i < 0 {
+				return pos, errors.New("keep value '" + args[pos] + "' is not a number")
+			}
+			all.T4C.Keep = i
+		case "--t4c-cache":
+			if len(all.T4C.Cache) > 0 {
+				return pos, errors.New("cache option " + args[pos] + " defined more than one time")
+			}
+			if pos+1 > len(args)-1 {
+				return pos, errors.New("cache command (list, prune or clear) not defined for " + args[pos] + " option")
+			}
+			pos++
+			if args[pos] != cacheList && args[pos] != cachePrune && args[pos] != cacheClear {
+				return pos, errors.New("unknown cache command: " + args[pos])
+			}
+			all.T4C.Cache = args[pos]
+			if all.T4C.Cache == cachePrune {
+				all.T4C.OlderThan = defaultPruneAge
+			}
+		case "--older-than":
+			if all.T4C.Cache != cachePrune {
+				return pos, errors.New("option " + args[pos] + " can only be used with --t4c-cache prune")
+			}
+			if pos+1 > len(args)-1 {
+				return pos, errors.New("age not defined for " + args[pos] + " option")
+			}
+			pos++
+			age, err := parseAge(args[pos])
+			if err != nil {
+				return pos, err
+			}
+			all.T4C.OlderThan = age
+		case "--t4c-check-update":
+			all.T4C.CheckUpdate = true
+		case "--t4c-install-from":
+			if len(all.T4C.InstallFrom) > 0 {
+				return pos, errors.New("install option " + args[pos] + " defined more than one time")
+			}
+			if pos+1 > len(args)-1 {
+				return pos, errors.New("install path not defined for " + args[pos] + " option")
+			}
+			pos++
+			all.T4C.InstallFrom = args[pos]
 		default:
 			// move to the next options group
 			break out
@@ -165,10 +252,10 @@ func setCljOpts(all *allOpts, args []string, pos int) (int, error) {
 		}
 
 		if args[pos] == "-version" {
-			fmt.Fprintln(os.Stderr, "Clojure CLI version "+version)
+			fmt.Fprintln(os.Stderr, "Clojure CLI version "+cliVersion(all))
 			return -1, nil
 		} else if args[pos] == "--version" {
-			fmt.Fprintln(os.Stdout, "Clojure CLI version "+version)
+			fmt.Fprintln(os.Stdout, "Clojure CLI version "+cliVersion(all))
 			return -1, nil
 		} else if strings.HasPrefix(args[pos], "-J") {
 			all.Clj.JvmOpts = append(all.Clj.JvmOpts, strings.TrimPrefix(args[pos], "-J"))
@@ -252,6 +339,8 @@ func setCljOpts(all *allOpts, args []string, pos int) (int, error) {
 			return pos, errors.New("option changed, use: clj -X:deps git-resolve-tags")
 		} else if args[pos] == "-Sverbose" {
 			all.Clj.Verbose = true
+		} else if args[pos] == "-Sexplain" {
+			all.Clj.Explain = true
 		} else if args[pos] == "-Sdescribe" {
 			all.Clj.Describe = true
 		} else if args[pos] == "-Sthreads" {
@@ -269,6 +358,15 @@ func setCljOpts(all *allOpts, args []string, pos int) (int, error) {
 			all.Clj.Threads = i
 		} else if args[pos] == "-Strace" {
 			all.Clj.Trace = true
+		} else if args[pos] == "-Scache-dir" {
+			if len(all.Clj.CacheDir) > 0 {
+				return pos, errors.New("cache dir option " + args[pos] + " defined more than one time")
+			}
+			if pos+1 > len(args)-1 {
+				return pos, errors.New("cache dir value (DIR) not defined for -Scache-dir option")
+			}
+			pos++
+			all.Clj.CacheDir = args[pos]
 		} else if strings.HasPrefix(args[pos], "-S") {
 			return pos, errors.New("invalid option:" + args[pos])
 		} else if args[pos] == "--" {
@@ -286,6 +384,14 @@ func setCljOpts(all *allOpts, args []string, pos int) (int, error) {
 	return pos, nil
 }
 
+// the clojure tools version selected on the command line, or the one in use
+func cliVersion(all *allOpts) string {
+	if len(all.T4C.ToolsVersion) > 0 {
+		return all.T4C.ToolsVersion
+	}
+	return toolsVersion
+}
+
 func setInitOpts(all *allOpts, args []string, pos int) (int, error) {
 	for {
 		if pos >= len(args) {
@@ -357,6 +463,112 @@ func setMainOpts(all *allOpts, args []string, pos int) (int, error) {
 	return pos, nil
 }
 
+// Check for stale classpath, and refresh it when needed. Concurrent
+// processes share the lock of the cache key, so that one of them runs
+// make-classpath, while the others wait and reuse its classpath.
+func refreshClassPath(options *allOpts, config *t4cConfig, cacheDir string, configPaths []string, explain func(string)) error {
+	if !options.Clj.Describe {
+		lock, err := lockClassPath(config.lockFile, cacheDir)
+		if err != nil {
+			return err
+		}
+		if lock != nil {
+			defer lock.release()
+		}
+	}
+
+	stale, err := explainStale(options, *config, configPaths, explain)
+	if err != nil {
+		return err
+	}
+
+	// Make tools args if needed
+	buildToolsArgs(config, stale, options)
+
+	// If stale, run make-classpath to refresh cached classpath
+	if stale && !options.Clj.Describe {
+		if options.Clj.Verbose {
+			fmt.Fprintln(os.Stderr, "Refreshing classpath")
+		}
+		err := start(makeClassPathCmd(config, toolsCp))
+		if err != nil {
+			return err
+		}
+		if staleCheckMode() == staleCheckContent {
+			err = recordClasspathInputs(options, *config, configPaths)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Record what the cached classpath is for, and when last used
+	if fileExists(config.cpFile) {
+		touchCacheMeta(*config, options, stale)
+	}
+	return nil
+}
+
+// The lock of a cached classpath. It returns a nil lock, when the lock
+// file can not be created, like in read-only caches, where there is
+// nothing to refresh anyway. Lock timeouts are always shown, as use()
+// errors are shown only with -Sverbose.
+func lockClassPath(lockFile string, cacheDir string) (*fileLock, error) {
+	err := os.MkdirAll(cacheDir, os.ModePerm)
+	if err != nil {
+		return nil, nil
+	}
+	file, err := os.OpenFile(lockFile, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, nil
+	}
+	file.Close()
+
+	lock, err := acquireLock(lockFile,
+		envDuration("T4C_LOCK_TIMEOUT", classpathLockTimeout),
+		"waiting for another process to compute the classpath")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, err
+	}
+	return lock, nil
+}
+
+// The project cache, when the project directory is writable, or else
+// the user cache, keyed by the project path. The user cache is also used
+// when forced, with -Scache-dir DIR, T4C_USER_CACHE or :t4c/user-cache.
+func selectCacheDir(options *allOpts, configDir string) (string, string, error) {
+	if !fileExists("deps.edn") {
+		if len(options.Clj.CacheDir) > 0 {
+			return options.Clj.CacheDir, "", nil
+		}
+		cacheDir, err := getCljCacheDir(configDir)
+		return cacheDir, "", err
+	}
+	if len(options.Clj.CacheDir) == 0 && !isUserCacheForced() && !isReadOnlyDir(".") {
+		return ".cpcache", "", nil
+	}
+
+	cacheDirKey, err := os.Getwd()
+	if err != nil {
+		return "", "", err
+	}
+	if len(options.Clj.CacheDir) > 0 {
+		return options.Clj.CacheDir, cacheDirKey, nil
+	}
+	cacheDir, err := getCljCacheDir(configDir)
+	return cacheDir, cacheDirKey, err
+}
+
+// keep project directories free of .cpcache
+func isUserCacheForced() bool {
+	_, found := os.LookupEnv("T4C_USER_CACHE")
+	if found {
+		return envTrue("T4C_USER_CACHE")
+	}
+	return settings.value(":t4c/user-cache") == "true"
+}
+
 func use(options *allOpts) error {
 	// Determine user config directory
 	configDir, err := getConfigDir()
@@ -386,27 +598,9 @@ func use(options *allOpts) error {
 	configPaths := getConfigPaths(&config, configDir, tools4CljDir, options.Clj.Repro)
 
 	// Determine whether to use user or project cache
-	cacheDir := ""
-	cacheDirKey := ""
-	if fileExists("deps.edn") {
-		if !isReadOnlyDir(".") {
-			cacheDir = ".cpcache"
-		} else {
-			cacheDirKey, err = os.Getwd()
-			if err != nil {
-				return err
-			}
-			cacheDir, err = getCljCacheDir(configDir)
-			if err != nil {
-				return err
-			}
-		}
-	} else {
-		// Use user cache directory
-		cacheDir, err = getCljCacheDir(configDir)
-		if err != nil {
-			return err
-		}
+	cacheDir, cacheDirKey, err := selectCacheDir(options, configDir)
+	if err != nil {
+		return err
 	}
 
 	// Calculate a checksum based on current options and config paths
@@ -417,37 +611,32 @@ func use(options *allOpts) error {
 	buildCmdConfigs(&config, cacheDir, ck)
 
 	if options.Clj.Verbose {
-		fmt.Fprintln(os.Stderr, "version      = "+version)
+		fmt.Fprintln(os.Stderr, "version      = "+toolsVersion)
 		fmt.Fprintln(os.Stderr, "install_dir  = "+tools4CljDir)
+		fmt.Fprintln(os.Stderr, "tools_source = "+getToolsSource(tools4CljDir))
 		fmt.Fprintln(os.Stderr, "config_dir   = "+configDir)
 		fmt.Fprintln(os.Stderr, "config_paths = "+join(configPaths, " "))
 		fmt.Fprintln(os.Stderr, "root_deps    = "+toolsCp)
 		fmt.Fprintln(os.Stderr, "user_deps    = "+config.configUser)
 		fmt.Fprintln(os.Stderr, "project_deps = "+config.configProject)
 		fmt.Fprintln(os.Stderr, "cache_dir    = "+cacheDir)
+		fmt.Fprintln(os.Stderr, "java_cmd     = "+javaRuntime.path)
+		fmt.Fprintln(os.Stderr, "java_version = "+javaRuntime.describe())
 		fmt.Fprintln(os.Stderr, "cp_file      = "+config.cpFile)
 	}
 
-	// Check for stale classpath
-	stale, err := isStale(options, config, configPaths)
+	// Check for stale classpath, explaining why on -Sexplain or -Sverbose
+	explain := noExplain
+	if options.Clj.Explain || options.Clj.Verbose {
+		explain = func(reason string) {
+			fmt.Fprintln(os.Stderr, "stale_check  = "+reason)
+		}
+	}
+	err = refreshClassPath(options, &config, cacheDir, configPaths, explain)
 	if err != nil {
 		return err
 	}
 
-	// Make tools args if needed
-	buildToolsArgs(&config, stale, options)
-
-	// If stale, run make-classpath to refresh cached classpath
-	if stale && !options.Clj.Describe {
-		if options.Clj.Verbose {
-			fmt.Fprintln(os.Stderr, "Refreshing classpath")
-		}
-		err := start(makeClassPathCmd(&config, toolsCp))
-		if err != nil {
-			return err
-		}
-	}
-
 	// Get active classpath to use
 	cp, err := activeClassPath(options, config)
 	if err != nil {
@@ -511,11 +700,18 @@ func use(options *allOpts) error {
 	return nil
 }
 
+// The cache key, a sha256 of the options, the java in use and the config
+// paths along with the digests of their contents. Keys of older versions
+// are crc32 numbers, so their .cpcache entries are never matched, and
+// are left for the official clojure tools sharing the cache, not even
+// removed by --t4c-cache.
 func checksumOf(options *allOpts, configPaths []string, cacheDirKey string) string {
-	var cacheVersion = "6"
+	var cacheVersion = "8"
 	prep := join([]string{
 		cacheVersion,
 		cacheDirKey,
+		javaRuntime.path,
+		javaRuntime.version,
 		join(options.Clj.ReplAliases, ""),
 		options.Clj.ExecAliases,
 		options.Clj.MainAliases,
@@ -524,80 +720,132 @@ func checksumOf(options *allOpts, configPaths []string, cacheDirKey string) stri
 		options.Clj.ToolAliases}, "|")
 	for _, v := range configPaths {
 		if fileExists(v) {
-			prep += "|" + v
+			digest, err := fileSha256(v)
+			if err != nil {
+				digest = "?"
+			}
+			prep += "|" + v + "=" + digest
 		} else {
 			prep += "|NIL"
 		}
 	}
-	c := crc32.ChecksumIEEE([]byte(prep))
-	return fmt.Sprintf("%d", c)
+	h := sha256.Sum256([]byte(prep))
+	return hex.EncodeToString(h[:])
 }
 
 func isStale(options *allOpts, config t4cConfig, configPaths []string) (bool, error) {
-	if options.Clj.Force || options.Clj.Trace || options.Clj.Tree || options.Clj.Prep || !fileExists(config.cpFile) {
+	return explainStale(options, config, configPaths, noExplain)
+}
+
+func noExplain(string) {}
+
+// Check whether the cached classpath is stale, explaining each rule
+// evaluated, along with the file and modification times that decided it.
+func explainStale(options *allOpts, config t4cConfig, configPaths []string, explain func(string)) (bool, error) {
+	flags := []struct {
+		name string
+		set  bool
+	}{
+		{"-Sforce", options.Clj.Force},
+		{"-Strace", options.Clj.Trace},
+		{"-Stree", options.Clj.Tree},
+		{"-P", options.Clj.Prep},
+	}
+	for _, flag := range flags {
+		if flag.set {
+			explain(flag.name + " is set: stale")
+			return true, nil
+		}
+	}
+	explain("none of -Sforce, -Strace, -Stree, -P is set")
+
+	stats := newFileStats()
+	if !stats.fileExists(config.cpFile) {
+		explain("cp file " + config.cpFile + " does not exist: stale")
 		return true, nil
-	} else {
-		newer := false
-		if len(options.Clj.ToolName) > 0 {
-			configDir, err := getConfigDir()
-			if err != nil {
-				return false, err
-			}
-			cljToolsDir := getCljToolsDir(configDir)
-			newer, err = checkIsNewerFile(path.Join(cljToolsDir, options.Clj.ToolName+".edn"), config.cpFile)
-			if err != nil {
-				return false, err
-			}
+	}
+	explain("cp file " + config.cpFile + " exists, modified " + stats.modTime(config.cpFile))
+
+	if staleCheckMode() == staleCheckContent {
+		return isContentStale(options, config, configPaths, stats, explain)
+	}
+
+	if len(options.Clj.ToolName) > 0 {
+		configDir, err := getConfigDir()
+		if err != nil {
+			return false, err
 		}
-		if newer {
+		toolFile := path.Join(getCljToolsDir(configDir), options.Clj.ToolName+".edn")
+		if stats.isNewer(toolFile, config.cpFile) {
+			explain("tool file " + toolFile + ", modified " + stats.modTime(toolFile) + ", is newer than the cp file: stale")
 			return true, nil
-		} else {
-			if fileExists(config.cpFile) {
-				b, err := os.ReadFile(config.cpFile)
-				if err != nil {
-					return false, err
-				}
-				for _, entry := range strings.Split(string(b), ":") {
-					if strings.HasSuffix(entry, ".jar") && !fileExists(entry) {
-						return true, nil
-					}
-				}
-			}
-
-			for _, path := range configPaths {
-				newer, err := checkIsNewerFile(path, config.cpFile)
-				if err != nil {
-					return false, err
-				}
-				if newer {
-					return true, nil
-				}
-			}
-
-			if fileExists(config.manifestFile) {
-				manifests, err := readNonEmptyLines(config.manifestFile)
-				if err != nil {
-					return false, err
-				}
-				for _, manifest := range manifests {
-					if !fileExists(manifest) {
-						return true, nil
-					}
-					newer, err := checkIsNewerFile(manifest, config.cpFile)
-					if err != nil {
-						return false, err
-					}
-					if newer {
-						return true, nil
-					}
-				}
-			}
-
-			if fileExists(options.Clj.DepsData) && (options.Clj.DepsData != config.cpFile) {
-				return true, nil
+		}
+		explain("tool file " + toolFile + ", modified " + stats.modTime(toolFile) + ", is not newer than the cp file")
+	}
+
+	stale, err := hasMissingEntries(config, stats, explain)
+	if err != nil || stale {
+		return stale, err
+	}
+
+	for _, path := range configPaths {
+		if stats.isNewer(path, config.cpFile) {
+			explain("config path " + path + ", modified " + stats.modTime(path) + ", is newer than the cp file: stale")
+			return true, nil
+		}
+		explain("config path " + path + ", modified " + stats.modTime(path) + ", is not newer than the cp file")
+	}
+
+	if stats.fileExists(config.manifestFile) {
+		manifests, err := readNonEmptyLines(config.manifestFile)
+		if err != nil {
+			return false, err
+		}
+		i := firstOf(manifests, func(manifest string) bool {
+			return !stats.fileExists(manifest) || stats.isNewer(manifest, config.cpFile)
+		})
+		if i >= 0 {
+			if !stats.fileExists(manifests[i]) {
+				explain("manifest " + manifests[i] + " does not exist: stale")
+			} else {
+				explain("manifest " + manifests[i] + ", modified " + stats.modTime(manifests[i]) + ", is newer than the cp file: stale")
 			}
+			return true, nil
 		}
+		explain("none of the " + strconv.Itoa(len(manifests)) + " manifests of " + config.manifestFile + " is newer than the cp file")
+	}
+
+	if stats.fileExists(options.Clj.DepsData) && (options.Clj.DepsData != config.cpFile) {
+		explain("-Sdeps file " + options.Clj.DepsData + " is used: stale")
+		return true, nil
 	}
+
+	explain("classpath is up to date")
+	return false, nil
+}
+
+// a cached classpath is stale, when any of its jars or deps is removed
+func hasMissingEntries(config t4cConfig, stats *fileStats, explain func(string)) (bool, error) {
+	if !stats.fileExists(config.cpFile) {
+		return false, nil
+	}
+	cp, err := readClassPath(config.cpFile)
+	if err != nil {
+		return false, err
+	}
+	roots, err := depRoots(config.manifestFile)
+	if err != nil {
+		return false, err
+	}
+	missing, err := cp.missingEntries(stats, roots)
+	if err != nil {
+		return false, err
+	}
+	if len(missing) > 0 {
+		explain("classpath entry " + missing[0] + " does not exist: stale")
+		return true, nil
+	}
+	explain("all classpath jars and deps directories exist")
 	return false, nil
 }
 
@@ -648,27 +896,26 @@ func activeClassPath(options *allOpts, config t4cConfig) (string, error) {
 	} else if len(options.Clj.ForceCP) > 0 {
 		cp = options.Clj.ForceCP
 	} else {
-		b, err := ioutil.ReadFile(config.cpFile)
+		classPath, err := readClassPath(config.cpFile)
 		if err != nil {
 			return "", err
 		}
-		if len(b) > 2048 {
-			cp = "@" + config.cpFile
-		} else {
-			cp = string(b)
-		}
+		cp = classPath.String()
 	}
 	return cp, nil
 }
 
 func argsDescription(pathVector string, toolsDir string, configDir string, cacheDir string, config *t4cConfig, options *allOpts) string {
-	return `{:version "` + version + `"
+	return `{:version "` + toolsVersion + `"
  :config-files [` + escOnWindows(pathVector) + `]
  :config-user "` + escOnWindows(config.configUser) + `"
  :config-project "` + escOnWindows(config.configProject) + `"
  :install-dir "` + escOnWindows(toolsDir) + `"
  :config-dir "` + escOnWindows(configDir) + `"
  :cache-dir "` + escOnWindows(cacheDir) + `"
+ :java-cmd "` + escOnWindows(javaRuntime.path) + `"
+ :java-version "` + javaRuntime.version + `"
+ :java-vendor "` + javaRuntime.vendor + `"
  :force ` + strconv.FormatBool(options.Clj.Force) + `
  :repro ` + strconv.FormatBool(options.Clj.Repro) + `
  :main-aliases "` + options.Clj.MainAliases + `"
@@ -125,6 +125,191 @@ var testT4CRlwrapItems = []TestReadItem{
 	},
 }
 
+var testT4CInstallItems = []TestReadItem{
+	{ // clojure, install from local path
+		[]string{"clojure", "--t4c-install-from", "clojure-tools.tar.gz"},
+		allOpts{
+			Clj:  cljOpts{},
+			Init: initOpts{},
+			Main: mainOpts{},
+			T4C: t4cOpts{
+				InstallFrom: "clojure-tools.tar.gz",
+			},
+			Args:       []string{},
+			NativeArgs: true,
+			Rlwrap:     false,
+			Mode:       "repl",
+		},
+		"",
+	},
+	{ // clojure, quiet
+		[]string{"clojure", "--t4c-quiet", "--t4c-install-from", "clojure-tools.tar.gz"},
+		allOpts{
+			Clj:  cljOpts{},
+			Init: initOpts{},
+			Main: mainOpts{},
+			T4C: t4cOpts{
+				InstallFrom: "clojure-tools.tar.gz",
+				Quiet:       true,
+			},
+			Args:       []string{},
+			NativeArgs: true,
+			Rlwrap:     false,
+			Mode:       "repl",
+		},
+		"",
+	},
+	{ // clojure, tools version
+		[]string{"clojure", "--t4c-tools-version", "1.11.1.1429"},
+		allOpts{
+			Clj:  cljOpts{},
+			Init: initOpts{},
+			Main: mainOpts{},
+			T4C: t4cOpts{
+				ToolsVersion: "1.11.1.1429",
+			},
+			Args:       []string{},
+			NativeArgs: true,
+			Rlwrap:     false,
+			Mode:       "repl",
+		},
+		"",
+	},
+	{ // clojure, invalid tools version
+		[]string{"clojure", "--t4c-tools-version", "latest"},
+		allOpts{},
+		"invalid clojure tools version: latest",
+	},
+	{ // clojure, tools version defined twice
+		[]string{"clojure", "--t4c-tools-version", "1.11.1.1429", "--t4c-tools-version", "1.12.0.1479"},
+		allOpts{},
+		"tools version option --t4c-tools-version defined more than one time",
+	},
+	{ // clojure, install from missing path
+		[]string{"clojure", "--t4c-install-from"},
+		allOpts{},
+		"install path not defined for --t4c-install-from option",
+	},
+	{ // clojure, install from defined twice
+		[]string{"clojure", "--t4c-install-from", "a.tar.gz", "--t4c-install-from", "b.tar.gz"},
+		allOpts{},
+		"install option --t4c-install-from defined more than one time",
+	},
+	{ // clojure, list versions
+		[]string{"clojure", "--t4c-versions"},
+		allOpts{
+			Clj:  cljOpts{},
+			Init: initOpts{},
+			Main: mainOpts{},
+			T4C: t4cOpts{
+				Versions: true,
+			},
+			Args:       []string{},
+			NativeArgs: true,
+			Rlwrap:     false,
+			Mode:       "repl",
+		},
+		"",
+	},
+	{ // clojure, gc versions
+		[]string{"clojure", "--t4c-gc", "--keep", "2"},
+		allOpts{
+			Clj:  cljOpts{},
+			Init: initOpts{},
+			Main: mainOpts{},
+			T4C: t4cOpts{
+				GC:   true,
+				Keep: 2,
+			},
+			Args:       []string{},
+			NativeArgs: true,
+			Rlwrap:     false,
+			Mode:       "repl",
+		},
+		"",
+	},
+	{ // clojure, check update
+		[]string{"clojure", "--t4c-check-update"},
+		allOpts{
+			Clj:  cljOpts{},
+			Init: initOpts{},
+			Main: mainOpts{},
+			T4C: t4cOpts{
+				CheckUpdate: true,
+			},
+			Args:       []string{},
+			NativeArgs: true,
+			Rlwrap:     false,
+			Mode:       "repl",
+		},
+		"",
+	},
+	{ // clojure, cache prune
+		[]string{"clojure", "--t4c-cache", "prune", "--older-than", "7d"},
+		allOpts{
+			Clj:  cljOpts{},
+			Init: initOpts{},
+			Main: mainOpts{},
+			T4C: t4cOpts{
+				Cache:     "prune",
+				OlderThan: 7 * 24 * time.Hour,
+			},
+			Args:       []string{},
+			NativeArgs: true,
+			Rlwrap:     false,
+			Mode:       "repl",
+		},
+		"",
+	},
+	{ // clojure, cache prune, default age
+		[]string{"clojure", "--t4c-cache", "prune"},
+		allOpts{
+			Clj:  cljOpts{},
+			Init: initOpts{},
+			Main: mainOpts{},
+			T4C: t4cOpts{
+				Cache:     "prune",
+				OlderThan: defaultPruneAge,
+			},
+			Args:       []string{},
+			NativeArgs: true,
+			Rlwrap:     false,
+			Mode:       "repl",
+		},
+		"",
+	},
+	{ // clojure, unknown cache command
+		[]string{"clojure", "--t4c-cache", "purge"},
+		allOpts{},
+		"unknown cache command: purge",
+	},
+	{ // clojure, older than without prune
+		[]string{"clojure", "--t4c-cache", "list", "--older-than", "7d"},
+		allOpts{},
+		"option --older-than can only be used with --t4c-cache prune",
+	},
+	{ // clojure, invalid age
+		[]string{"clojure", "--t4c-cache", "prune", "--older-than", "month"},
+		allOpts{},
+		"invalid age: month",
+	},
+	{ // clojure, keep without gc
+		[]string{"clojure", "--keep", "2"},
+		allOpts{},
+		"option --keep can only be used with --t4c-gc",
+	},
+	{ // clojure, keep without value
+		[]string{"clojure", "--t4c-gc", "--keep"},
+		allOpts{},
+		"keep value (N) not defined for --keep option",
+	},
+	{ // clojure, keep not a number
+		[]string{"clojure", "--t4c-gc", "--keep", "all"},
+		allOpts{},
+		"keep value 'all' is not a number",
+	},
+}
+
 var testMainItems = []TestReadItem{
 	{ // abnormal totally missing args
 		[]string{},
@@ -368,6 +553,7 @@ var testDepItems = []TestReadItem{
 			"-Spom",
 			"-Stree",
 			"-Sverbose",
+			"-Sexplain",
 			"-Sdescribe",
 			"-Sthreads",
 			"42",
@@ -385,6 +571,7 @@ var testDepItems = []TestReadItem{
 				Pom:            true,
 				Tree:           true,
 				Verbose:        true,
+				Explain:        true,
 				Describe:       true,
 				Threads:        42,
 				Trace:          true,
@@ -534,6 +721,57 @@ var testDepItems = []TestReadItem{
 		},
 		"threads value '" + "not-a-number" + "' is not a number",
 	},
+	{ // Dep option: -Scache-dir
+		[]string{"clojure",
+			"-Scache-dir",
+			"/tmp/cpcache",
+		},
+		allOpts{
+			Clj: cljOpts{
+				CacheDir: "/tmp/cpcache",
+			},
+			Init:       initOpts{},
+			Main:       mainOpts{},
+			Args:       []string{},
+			NativeArgs: true,
+			Rlwrap:     false,
+			Mode:       "repl",
+		},
+		"",
+	},
+	{ // not valid Dep multiple option: -Scache-dir
+		[]string{"clojure",
+			"-Scache-dir",
+			"/tmp/cpcache",
+			"-Scache-dir",
+			"/tmp/other",
+		},
+		allOpts{
+			Clj:        cljOpts{},
+			Init:       initOpts{},
+			Main:       mainOpts{},
+			Args:       []string{},
+			NativeArgs: true,
+			Rlwrap:     false,
+			Mode:       "repl",
+		},
+		"cache dir option -Scache-dir defined more than one time",
+	},
+	{ // missing value for Dep option: -Scache-dir
+		[]string{"clojure",
+			"-Scache-dir",
+		},
+		allOpts{
+			Clj:        cljOpts{},
+			Init:       initOpts{},
+			Main:       mainOpts{},
+			Args:       []string{},
+			NativeArgs: true,
+			Rlwrap:     false,
+			Mode:       "repl",
+		},
+		"cache dir value (DIR) not defined for -Scache-dir option",
+	},
 	{ // pass remaining dep options to clojure.main, by using Dep option: --
 		[]string{"clojure",
 			"-A:argA1",
@@ -1062,6 +1300,7 @@ func TestRead(t *testing.T) {
 	testItems := []TestReadItem{}
 	testItems = append(testItems, testT4CNativeArgsItems...)
 	testItems = append(testItems, testT4CRlwrapItems...)
+	testItems = append(testItems, testT4CInstallItems...)
 	testItems = append(testItems, testMainItems...)
 	testItems = append(testItems, testDepItems...)
 	testItems = append(testItems, testInitItems...)
@@ -1119,7 +1358,7 @@ func TestChecksumOf(t *testing.T) {
 	// empty cache dir key
 	cacheDirKey := ""
 	// output
-	expected := "272585735"
+	expected := "153d1b57bb73c10997a07ef59e697e6dca81c24c321cfbe3d9cd9f505559fd43"
 
 	res := checksumOf(&options, configPaths, cacheDirKey)
 	if res != expected {
@@ -1137,7 +1376,7 @@ func TestChecksumOf(t *testing.T) {
 	}
 
 	// different output is expected
-	expected = "2234442315"
+	expected = "369365e8c3bc723fca6efb02b2934ebea84600fa7bc009cea771e3a4d2d4beb4"
 
 	res = checksumOf(&options, configPaths, cacheDirKey)
 	if res != expected {
@@ -1148,7 +1387,34 @@ func TestChecksumOf(t *testing.T) {
 	cacheDirKey = "currentdir"
 
 	// different output is expected
-	expected = "1515385266"
+	expected = "93053b5efe9ec21412e7fe4d4bc0b559eccbf65beb30d77f1105bae03eacf74b"
+
+	res = checksumOf(&options, configPaths, cacheDirKey)
+	if res != expected {
+		t.Errorf("checksumOf failed, expected %v, got %v", expected, res)
+	}
+
+	// switch to another java
+	defer func(j javaInfo) { javaRuntime = j }(javaRuntime)
+	javaRuntime = javaInfo{path: "/usr/lib/jvm/java-21/bin/java", version: "21.0.1"}
+
+	// different output is expected
+	expected = "99eabca8df337550cefa1927b0f2627f7124d7f1614d92d259509af934d881e1"
+
+	res = checksumOf(&options, configPaths, cacheDirKey)
+	if res != expected {
+		t.Errorf("checksumOf failed, expected %v, got %v", expected, res)
+	}
+
+	// change the content of the config file, at the same path
+	err = os.WriteFile(tmpExistingFile, []byte("Hello, again"), 0755)
+	if err != nil {
+		t.Errorf("unable to write file: %v", err)
+		t.FailNow()
+	}
+
+	// different output is expected
+	expected = "731db559cbf21296099149bf6af78c3d1a0d1caa9f071deab59c8c4ae638b29f"
 
 	res = checksumOf(&options, configPaths, cacheDirKey)
 	if res != expected {
@@ -1551,6 +1817,137 @@ func TestIsStale(t *testing.T) {
 	}
 }
 
+func TestExplainStale(t *testing.T) {
+	options := allOpts{}
+	cpFile := "classpathFile.edn"
+	configFile := "config_filepath.edn"
+	config := t4cConfig{cpFile: cpFile}
+	defer os.Remove(cpFile)
+	defer os.Remove(configFile)
+
+	reasons := []string{}
+	explain := func(reason string) {
+		reasons = append(reasons, reason)
+	}
+
+	// missing cp file
+	stale, err := explainStale(&options, config, []string{configFile}, explain)
+	if err != nil || !stale {
+		t.Errorf("expected stale classpath, got %v, error %v", stale, err)
+	}
+	expected := "cp file " + cpFile + " does not exist: stale"
+	if reasons[len(reasons)-1] != expected {
+		t.Errorf("wrong stale explanation, expected %v, got %v", expected, reasons)
+	}
+
+	// up to date
+	os.WriteFile(configFile, []byte("{}"), 0644)
+	time.Sleep(100 * time.Millisecond)
+	os.WriteFile(cpFile, []byte("src"), 0644)
+	reasons = []string{}
+	stale, err = explainStale(&options, config, []string{configFile}, explain)
+	if err != nil || stale {
+		t.Errorf("expected not stale classpath, got %v, error %v", stale, err)
+	}
+	if len(reasons) != 5 || reasons[4] != "classpath is up to date" ||
+		!strings.HasPrefix(reasons[3], "config path "+configFile+", modified ") {
+		t.Errorf("wrong stale explanation, got %v", reasons)
+	}
+
+	// newer config path
+	time.Sleep(100 * time.Millisecond)
+	os.WriteFile(configFile, []byte("{:deps {}}"), 0644)
+	reasons = []string{}
+	stale, err = explainStale(&options, config, []string{configFile}, explain)
+	if err != nil || !stale {
+		t.Errorf("expected stale classpath, got %v, error %v", stale, err)
+	}
+	last := reasons[len(reasons)-1]
+	if !strings.HasPrefix(last, "config path "+configFile) || !strings.HasSuffix(last, "is newer than the cp file: stale") {
+		t.Errorf("wrong stale explanation, got %v", last)
+	}
+
+	// forced
+	options.Clj.Force = true
+	reasons = []string{}
+	explainStale(&options, config, []string{configFile}, explain)
+	if len(reasons) != 1 || reasons[0] != "-Sforce is set: stale" {
+		t.Errorf("wrong stale explanation, got %v", reasons)
+	}
+}
+
+func TestRefreshClassPath(t *testing.T) {
+	cacheDir := "test-refresh-cpcache"
+	configFile := "config_refresh.edn"
+	defer os.RemoveAll(cacheDir)
+	defer os.Remove(configFile)
+
+	options := allOpts{}
+	config := t4cConfig{}
+	buildCmdConfigs(&config, cacheDir, strings.Repeat("0c", 32))
+
+	// up to date classpath, reused
+	os.WriteFile(configFile, []byte("{}"), 0644)
+	time.Sleep(100 * time.Millisecond)
+	os.MkdirAll(cacheDir, os.ModePerm)
+	os.WriteFile(config.cpFile, []byte("src"), 0644)
+	err := refreshClassPath(&options, &config, cacheDir, []string{configFile}, noExplain)
+	if err != nil {
+		t.Errorf("failed to refresh classpath: %v", err)
+	}
+	if !fileExists(config.lockFile) || !fileExists(config.metaFile) {
+		t.Error("expected the lock and meta files of the classpath")
+	}
+
+	// computed by another process
+	lock, err := acquireLock(config.lockFile, time.Second, "")
+	if err != nil {
+		t.Errorf("failed to acquire lock: %v", err)
+		t.FailNow()
+	}
+	os.Setenv("T4C_LOCK_TIMEOUT", "300ms")
+	err = refreshClassPath(&options, &config, cacheDir, []string{configFile}, noExplain)
+	os.Unsetenv("T4C_LOCK_TIMEOUT")
+	if err == nil || !strings.HasPrefix(err.Error(), "timed out after 300ms") {
+		t.Errorf("expected a lock timeout, got %v", err)
+	}
+
+	// describe only reads the cache, without waiting
+	options.Clj.Describe = true
+	err = refreshClassPath(&options, &config, cacheDir, []string{configFile}, noExplain)
+	if err != nil {
+		t.Errorf("failed to describe classpath: %v", err)
+	}
+	lock.release()
+}
+
+func TestLockClassPath(t *testing.T) {
+	cacheDir := "test-lock-cpcache"
+	defer os.RemoveAll(cacheDir)
+
+	// a cache dir that can not be created
+	os.WriteFile(cacheDir, []byte{}, 0644)
+	lock, err := lockClassPath(path.Join(cacheDir, "key.lock"), cacheDir)
+	if err != nil || lock != nil {
+		t.Errorf("expected no lock and no error, got %v, error %v", lock, err)
+	}
+	os.Remove(cacheDir)
+
+	// a lock file that can not be created
+	os.MkdirAll(path.Join(cacheDir, "key.lock"), os.ModePerm)
+	lock, err = lockClassPath(path.Join(cacheDir, "key.lock"), cacheDir)
+	if err != nil || lock != nil {
+		t.Errorf("expected no lock and no error, got %v, error %v", lock, err)
+	}
+
+	lock, err = lockClassPath(path.Join(cacheDir, "other.lock"), cacheDir)
+	if err != nil || lock == nil {
+		t.Errorf("failed to lock classpath: %v", err)
+		t.FailNow()
+	}
+	lock.release()
+}
+
 func TestIsStaleOnManifests(t *testing.T) {
 	options := allOpts{}
 	config := t4cConfig{}
@@ -1952,6 +2349,67 @@ func TestActiveClassPath(t *testing.T) {
 	}
 }
 
+func TestSelectCacheDir(t *testing.T) {
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	// a writable project directory
+	projectDir := t.TempDir()
+	os.Chmod(projectDir, os.ModePerm)
+	os.Chdir(projectDir)
+	projectDir, _ = os.Getwd()
+
+	configDir := path.Join(projectDir, "config")
+	os.Setenv("CLJ_CACHE", path.Join(projectDir, "user-cache"))
+	defer os.Unsetenv("CLJ_CACHE")
+	userCache := path.Join(projectDir, "user-cache")
+
+	testItems := []struct {
+		name        string
+		depsEdn     bool
+		userCache   string
+		cliCacheDir string
+		cacheDir    string
+		cacheDirKey string
+	}{
+		{"no project", false, "", "", userCache, ""},
+		{"no project, -Scache-dir", false, "", "cli-cache", "cli-cache", ""},
+		{"project", true, "", "", ".cpcache", ""},
+		{"project, user cache disabled", true, "0", "", ".cpcache", ""},
+		{"project, user cache", true, "1", "", userCache, projectDir},
+		{"project, -Scache-dir", true, "", "cli-cache", "cli-cache", projectDir},
+	}
+
+	for _, item := range testItems {
+		os.Remove("deps.edn")
+		if item.depsEdn {
+			os.WriteFile("deps.edn", []byte("{}"), 0644)
+		}
+		os.Unsetenv("T4C_USER_CACHE")
+		if item.userCache != "" {
+			os.Setenv("T4C_USER_CACHE", item.userCache)
+		}
+		options := allOpts{Clj: cljOpts{CacheDir: item.cliCacheDir}}
+
+		cacheDir, cacheDirKey, err := selectCacheDir(&options, configDir)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", item.name, err)
+		}
+		if cacheDir != item.cacheDir || cacheDirKey != item.cacheDirKey {
+			t.Errorf("%v: expected %v keyed by %v, got %v keyed by %v",
+				item.name, item.cacheDir, item.cacheDirKey, cacheDir, cacheDirKey)
+		}
+	}
+	os.Unsetenv("T4C_USER_CACHE")
+
+	// forced by the config setting
+	settings = t4cSettings{":t4c/user-cache": []string{"true"}}
+	defer func() { settings = t4cSettings{} }()
+	cacheDir, cacheDirKey, _ := selectCacheDir(&allOpts{}, configDir)
+	if cacheDir != userCache || cacheDirKey != projectDir {
+		t.Errorf("expected the user cache, got %v keyed by %v", cacheDir, cacheDirKey)
+	}
+}
+
 func TestArgsDescription(t *testing.T) {
 	// input
 	pathVector := "test" + string(os.PathSeparator) + "Path"
@@ -1978,6 +2436,9 @@ func TestArgsDescription(t *testing.T) {
  :install-dir "` + escOnWindows(toolsDir) + `"
  :config-dir "` + escOnWindows(configDir) + `"
  :cache-dir "` + escOnWindows(cacheDir) + `"
+ :java-cmd "` + escOnWindows(javaRuntime.path) + `"
+ :java-version "` + javaRuntime.version + `"
+ :java-vendor "` + javaRuntime.vendor + `"
  :force ` + strconv.FormatBool(options.Clj.Force) + `
  :repro ` + strconv.FormatBool(options.Clj.Repro) + `
  :main-aliases "` + options.Clj.MainAliases + `"
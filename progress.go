@@ -0,0 +1,103 @@
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// silence the tools4clj messages (--t4c-quiet, T4C_QUIET)
+var quiet = false
+
+// tools4clj messages go to stderr, keeping stdout clean for
+// the output of clojure (e.g. the classpath of -Spath)
+func t4cPrintln(message string) {
+	if !quiet {
+		fmt.Fprintln(os.Stderr, "[t4c] - "+message)
+	}
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// reports the progress of writes, as bytes, total and rate
+type progressWriter struct {
+	out     io.Writer
+	written int64
+	total   int64
+	offset  int64
+	start   time.Time
+	last    time.Time
+}
+
+// a progress writer on stderr, or nil when not on a terminal, or quiet
+func newStderrProgress(offset int64, total int64) *progressWriter {
+	if quiet || !isTerminal(os.Stderr) {
+		return nil
+	}
+	return newProgress(os.Stderr, offset, total)
+}
+
+func newProgress(out io.Writer, offset int64, total int64) *progressWriter {
+	return &progressWriter{out: out, written: offset, offset: offset, total: total, start: time.Now()}
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	p.written += int64(len(b))
+	if time.Since(p.last) >= 200*time.Millisecond {
+		p.last = time.Now()
+		p.print()
+	}
+	return len(b), nil
+}
+
+func (p *progressWriter) print() {
+	line := "\r[t4c] - " + formatBytes(p.written)
+	if p.total > 0 {
+		line += " / " + formatBytes(p.total)
+	}
+	elapsed := time.Since(p.start).Seconds()
+	if elapsed > 0 {
+		line += " (" + formatBytes(int64(float64(p.written-p.offset)/elapsed)) + "/s)"
+	}
+	// clear any leftovers of a longer previous line
+	fmt.Fprint(p.out, line+"   ")
+}
+
+// print the final progress, and move to the next line
+func (p *progressWriter) done() {
+	p.print()
+	fmt.Fprintln(p.out)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return strconv.FormatFloat(float64(n)/float64(div), 'f', 1, 64) + " " + string("KMGTPE"[exp]) + "iB"
+}
@@ -0,0 +1,80 @@
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFormatBytes(t *testing.T) {
+	testItems := []struct {
+		input    int64
+		expected string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{20 * 1024 * 1024, "20.0 MiB"},
+		{3 * 1024 * 1024 * 1024, "3.0 GiB"},
+	}
+
+	for _, v := range testItems {
+		res := formatBytes(v.input)
+		if res != v.expected {
+			t.Errorf("formatBytes of %v failed, expected %v, got %v", v.input, v.expected, res)
+		}
+	}
+}
+
+func TestProgressWriter(t *testing.T) {
+	var out bytes.Buffer
+
+	progress := newProgress(&out, 1024, 4096)
+	n, err := progress.Write(make([]byte, 1024))
+	if err != nil || n != 1024 {
+		t.Errorf("progress write failed, wrote %v, error %v", n, err)
+	}
+	progress.done()
+
+	res := out.String()
+	if !strings.Contains(res, "2.0 KiB / 4.0 KiB") {
+		t.Errorf("progress without bytes and total, got %v", res)
+	}
+	if !strings.Contains(res, "/s)") {
+		t.Errorf("progress without rate, got %v", res)
+	}
+	if !strings.HasSuffix(res, "\n") {
+		t.Errorf("progress done without new line, got %v", res)
+	}
+
+	// unknown total
+	out.Reset()
+	progress = newProgress(&out, 0, 0)
+	progress.Write(make([]byte, 10))
+	progress.done()
+	if strings.Contains(out.String(), " / ") {
+		t.Errorf("progress with unknown total, got %v", out.String())
+	}
+}
+
+func TestNewStderrProgress(t *testing.T) {
+	defer func(q bool) { quiet = q }(quiet)
+
+	quiet = true
+	if newStderrProgress(0, 100) != nil {
+		t.Error("progress is shown when quiet")
+	}
+}
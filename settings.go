@@ -0,0 +1,246 @@
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"errors"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// tools4clj settings, read from ~/.tools4clj/config.edn, and the
+// project .t4c.edn, overriding it; both are flat edn maps, like:
+//
+//	{:t4c/tools-url ["https://artifactory.example.com/clojure/"
+//	                 "https://download.clojure.org/install/"]}
+//
+// every key holds a list of values, single values are lists of one
+type t4cSettings map[string][]string
+
+const (
+	t4cConfigEDN  = "config.edn"
+	projectT4CEDN = ".t4c.edn"
+)
+
+var settings = t4cSettings{}
+
+// the settings file each of the settings was read from
+var settingsSources = map[string]string{}
+
+// The keys a project .t4c.edn may set. The others, like the tools download
+// urls, are trusted only from ~/.tools4clj/config.edn, not from any cloned
+// repository.
+var projectKeys = map[string]bool{
+	":t4c/tools-version": true,
+	":t4c/java-home":     true,
+	":t4c/stale-check":   true,
+	":t4c/user-cache":    true,
+}
+
+// without a home directory, only the project settings are read
+func getSettingsPaths() []string {
+	t4cDir, err := getT4CHomePath()
+	if err != nil {
+		return []string{projectT4CEDN}
+	}
+	return []string{path.Join(t4cDir, t4cConfigEDN), projectT4CEDN}
+}
+
+// read and merge the settings files, later files override earlier ones,
+// of the project .t4c.edn only its project keys; along with the file each
+// key was read from
+func loadSettings(files []string) (t4cSettings, map[string]string, error) {
+	all := t4cSettings{}
+	sources := map[string]string{}
+	for _, file := range files {
+		if !fileExists(file) {
+			continue
+		}
+		s, err := readSettings(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		for k, v := range s {
+			if file == projectT4CEDN && !projectKeys[k] {
+				continue
+			}
+			all[k] = v
+			sources[k] = file
+		}
+	}
+	return all, sources, nil
+}
+
+// The keys of the project .t4c.edn that are not project keys, ignored by
+// loadSettings; warned about once quiet mode is known.
+func ignoredProjectSettings() []string {
+	if !fileExists(projectT4CEDN) {
+		return []string{}
+	}
+	s, err := readSettings(projectT4CEDN)
+	if err != nil {
+		return []string{}
+	}
+	keys := []string{}
+	for k := range s {
+		if !projectKeys[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// the settings file a key was read from, for pointing users to it
+func settingSource(key string) string {
+	file, found := settingsSources[key]
+	if !found {
+		return "the tools4clj settings"
+	}
+	return file
+}
+
+func readSettings(file string) (t4cSettings, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	s, err := parseSettings(string(b))
+	if err != nil {
+		return nil, errors.New("invalid settings file " + file + ": " + err.Error())
+	}
+	return s, nil
+}
+
+func parseSettings(text string) (t4cSettings, error) {
+	tokens, err := ednTokens(text)
+	if err != nil {
+		return nil, err
+	}
+
+	s := t4cSettings{}
+	if len(tokens) == 0 {
+		return s, nil
+	}
+	if tokens[0] != "{" || tokens[len(tokens)-1] != "}" {
+		return nil, errors.New("expected a map")
+	}
+
+	tokens = tokens[1 : len(tokens)-1]
+	for i := 0; i < len(tokens); i++ {
+		key := tokens[i]
+		if !strings.HasPrefix(key, ":") {
+			return nil, errors.New("expected a keyword key, got " + key)
+		}
+		i++
+		if i >= len(tokens) {
+			return nil, errors.New("missing value for " + key)
+		}
+
+		switch tokens[i] {
+		case "[":
+			values := []string{}
+			for i++; i < len(tokens) && tokens[i] != "]"; i++ {
+				if isEdnDelimiter(tokens[i]) {
+					return nil, errors.New("unsupported nested value for " + key)
+				}
+				values = append(values, ednValue(tokens[i]))
+			}
+			if i >= len(tokens) {
+				return nil, errors.New("unterminated vector for " + key)
+			}
+			s[key] = values
+		case "{", "}", "]":
+			return nil, errors.New("unsupported value for " + key)
+		default:
+			s[key] = []string{ednValue(tokens[i])}
+		}
+	}
+	return s, nil
+}
+
+// value returns the first value of the key, or an empty string
+func (s t4cSettings) value(key string) string {
+	values := s[key]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (s t4cSettings) values(key string) []string {
+	return s[key]
+}
+
+func isEdnDelimiter(token string) bool {
+	return token == "{" || token == "}" || token == "[" || token == "]"
+}
+
+// strings are kept quoted while tokenizing, to distinguish them from delimiters
+func ednValue(token string) string {
+	if strings.HasPrefix(token, `"`) {
+		return strings.TrimPrefix(token, `"`)
+	}
+	return token
+}
+
+func ednTokens(text string) ([]string, error) {
+	tokens := []string{}
+	runes := []rune(text)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == ';':
+			// comment, skip to the end of line
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == ',' || r == ' ' || r == '\t' || r == '\r' || r == '\n':
+			// whitespace
+		case r == '{' || r == '}' || r == '[' || r == ']':
+			tokens = append(tokens, string(r))
+		case r == '"':
+			str := `"`
+			for i++; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					switch runes[i] {
+					case 'n':
+						str += "\n"
+					case 't':
+						str += "\t"
+					default:
+						str += string(runes[i])
+					}
+					continue
+				}
+				str += string(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, errors.New("unterminated string")
+			}
+			tokens = append(tokens, str)
+		default:
+			token := ""
+			for ; i < len(runes) && !strings.ContainsRune(" \t\r\n,;{}[]\"", runes[i]); i++ {
+				token += string(runes[i])
+			}
+			i--
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
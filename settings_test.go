@@ -0,0 +1,146 @@
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseSettings(t *testing.T) {
+	text := `; tools4clj settings
+{:t4c/tools-url ["https://mirror1.example.com/clojure/"
+                 "https://mirror2.example.com/clojure/"]
+ :t4c/name "with \"quotes\", and commas"
+ :t4c/number 30}`
+
+	s, err := parseSettings(text)
+	if err != nil {
+		t.Errorf("failed to parse settings: %v", err)
+		t.FailNow()
+	}
+
+	urls := s.values(":t4c/tools-url")
+	if len(urls) != 2 || urls[0] != "https://mirror1.example.com/clojure/" || urls[1] != "https://mirror2.example.com/clojure/" {
+		t.Errorf("wrong vector value, got %v", urls)
+	}
+	if s.value(":t4c/tools-url") != urls[0] {
+		t.Errorf("wrong first value, got %v", s.value(":t4c/tools-url"))
+	}
+
+	expected := `with "quotes", and commas`
+	if s.value(":t4c/name") != expected {
+		t.Errorf("wrong string value, expected %v, got %v", expected, s.value(":t4c/name"))
+	}
+	if s.value(":t4c/number") != "30" {
+		t.Errorf("wrong number value, expected %v, got %v", "30", s.value(":t4c/number"))
+	}
+	if s.value(":t4c/missing") != "" {
+		t.Errorf("expected empty value for missing key, got %v", s.value(":t4c/missing"))
+	}
+
+	// empty settings
+	s, err = parseSettings("  ; nothing here\n")
+	if err != nil {
+		t.Errorf("failed to parse empty settings: %v", err)
+	}
+	if len(s) != 0 {
+		t.Errorf("expected empty settings, got %v", s)
+	}
+
+	// invalid settings
+	invalid := []string{
+		`[:t4c/tools-url "url"]`,
+		`{:t4c/tools-url}`,
+		`{"t4c/tools-url" "url"}`,
+		`{:t4c/tools-url {:nested "map"}}`,
+		`{:t4c/tools-url ["url" ["nested"]]}`,
+		`{:t4c/tools-url "url}`,
+	}
+	for _, text := range invalid {
+		_, err = parseSettings(text)
+		if err == nil {
+			t.Errorf("expected to get an error for invalid settings: %v", text)
+		}
+	}
+}
+
+func TestLoadSettings(t *testing.T) {
+	tmpTestFile1 := "test-settings1.edn"
+	tmpTestFile2 := "test-settings2.edn"
+
+	err := os.WriteFile(tmpTestFile1, []byte(`{:t4c/a "1" :t4c/b "1"}`), 0644)
+	if err != nil {
+		t.Errorf("Unable to write file: %v", err)
+		t.FailNow()
+	} else {
+		defer os.Remove(tmpTestFile1)
+	}
+	err = os.WriteFile(tmpTestFile2, []byte(`{:t4c/b "2"}`), 0644)
+	if err != nil {
+		t.Errorf("Unable to write file: %v", err)
+		t.FailNow()
+	} else {
+		defer os.Remove(tmpTestFile2)
+	}
+
+	s, sources, err := loadSettings([]string{tmpTestFile1, "not-existing-settings.edn", tmpTestFile2})
+	if err != nil {
+		t.Errorf("failed to load settings: %v", err)
+	}
+	if s.value(":t4c/a") != "1" {
+		t.Errorf("wrong merged value, expected %v, got %v", "1", s.value(":t4c/a"))
+	}
+	if s.value(":t4c/b") != "2" {
+		t.Errorf("wrong overridden value, expected %v, got %v", "2", s.value(":t4c/b"))
+	}
+	if sources[":t4c/a"] != tmpTestFile1 || sources[":t4c/b"] != tmpTestFile2 {
+		t.Errorf("wrong settings sources, got %v", sources)
+	}
+
+	err = os.WriteFile(tmpTestFile2, []byte(`{:t4c/b`), 0644)
+	if err != nil {
+		t.Errorf("Unable to write file: %v", err)
+		t.FailNow()
+	}
+	_, _, err = loadSettings([]string{tmpTestFile1, tmpTestFile2})
+	if err == nil {
+		t.Error("expected to get an error for invalid settings file")
+	}
+	// only the project keys of .t4c.edn
+	if fileExists(projectT4CEDN) {
+		t.Skip("a " + projectT4CEDN + " already exists")
+	}
+	err = os.WriteFile(projectT4CEDN, []byte(`{:t4c/tools-version "1.12.0.1479"
+ :t4c/tools-url "https://evil.example.com/"
+ :t4c/update-url "https://evil.example.com/latest"}`), 0644)
+	if err != nil {
+		t.Errorf("Unable to write file: %v", err)
+		t.FailNow()
+	}
+	defer os.Remove(projectT4CEDN)
+	os.WriteFile(tmpTestFile2, []byte(`{:t4c/tools-url "https://artifactory.example.com/"}`), 0644)
+	s, _, err = loadSettings([]string{tmpTestFile2, projectT4CEDN})
+	if err != nil {
+		t.Errorf("failed to load settings: %v", err)
+	}
+	if s.value(":t4c/tools-version") != "1.12.0.1479" ||
+		s.value(":t4c/tools-url") != "https://artifactory.example.com/" ||
+		s.value(":t4c/update-url") != "" {
+		t.Errorf("wrong project settings, got %v", s)
+	}
+	ignored := ignoredProjectSettings()
+	if join(ignored, " ") != ":t4c/tools-url :t4c/update-url" {
+		t.Errorf("wrong ignored project settings, got %v", ignored)
+	}
+}
@@ -0,0 +1,121 @@
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// How many files are checked concurrently, for staleness. Checking is
+// mostly waiting on the file system, for the jars of large classpaths.
+var staleCheckWorkers = min(16, 2*runtime.NumCPU())
+
+// Memoized stats of the files checked within a staleness check, where
+// the same files, like the cp file, are checked again and again.
+type fileStats struct {
+	mu    sync.Mutex
+	infos map[string]os.FileInfo
+}
+
+func newFileStats() *fileStats {
+	return &fileStats{infos: map[string]os.FileInfo{}}
+}
+
+// the info of a file or directory, nil when it does not exist
+func (s *fileStats) stat(name string) os.FileInfo {
+	s.mu.Lock()
+	info, found := s.infos[name]
+	s.mu.Unlock()
+	if found {
+		return info
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		info = nil
+	}
+	s.mu.Lock()
+	s.infos[name] = info
+	s.mu.Unlock()
+	return info
+}
+
+func (s *fileStats) fileExists(name string) bool {
+	info := s.stat(name)
+	return info != nil && !info.IsDir()
+}
+
+func (s *fileStats) dirExists(name string) bool {
+	info := s.stat(name)
+	return info != nil && info.IsDir()
+}
+
+// as checkIsNewerFile, with a single stat of each file
+func (s *fileStats) isNewer(file1 string, file2 string) bool {
+	if !s.fileExists(file1) {
+		return false
+	}
+	if !s.fileExists(file2) {
+		return true
+	}
+	return s.stat(file1).ModTime().UnixNano() > s.stat(file2).ModTime().UnixNano()
+}
+
+// the modification time of a file, for explaining staleness
+func (s *fileStats) modTime(name string) string {
+	info := s.stat(name)
+	if info == nil {
+		return "(missing)"
+	}
+	return info.ModTime().Format("2006-01-02 15:04:05.000")
+}
+
+// Run check on each of the items, by up to staleCheckWorkers goroutines.
+// The results are in the order of the items.
+func checkAll(items []string, check func(string) bool) []bool {
+	results := make([]bool, len(items))
+	workers := min(staleCheckWorkers, len(items))
+	if workers <= 1 {
+		for i, item := range items {
+			results[i] = check(item)
+		}
+		return results
+	}
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int(next.Add(1) - 1); i < len(items); i = int(next.Add(1) - 1) {
+				results[i] = check(items[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// the first of the items, in order, check is true for, or -1
+func firstOf(items []string, check func(string) bool) int {
+	for i, found := range checkAll(items, check) {
+		if found {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,321 @@
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFileStats(t *testing.T) {
+	file := "test-stats.edn"
+	dir := "test-stats-dir"
+	defer os.Remove(file)
+	defer os.RemoveAll(dir)
+
+	os.WriteFile(file, []byte("{}"), 0644)
+	os.MkdirAll(dir, os.ModePerm)
+
+	stats := newFileStats()
+	if !stats.fileExists(file) || stats.dirExists(file) {
+		t.Errorf("expected %v to exist as a file", file)
+	}
+	if !stats.dirExists(dir) || stats.fileExists(dir) {
+		t.Errorf("expected %v to exist as a directory", dir)
+	}
+	if stats.fileExists("not-existing.edn") || stats.modTime("not-existing.edn") != "(missing)" {
+		t.Error("expected not-existing.edn to be missing")
+	}
+	if !stats.isNewer(file, "not-existing.edn") || stats.isNewer("not-existing.edn", file) {
+		t.Error("wrong isNewer with a missing file")
+	}
+
+	// memoized, within the same stats
+	os.Remove(file)
+	if !stats.fileExists(file) {
+		t.Errorf("expected the memoized stat of %v", file)
+	}
+	if newFileStats().fileExists(file) {
+		t.Errorf("expected %v to be removed", file)
+	}
+}
+
+func TestCheckAll(t *testing.T) {
+	items := []string{}
+	for i := 0; i < 100; i++ {
+		items = append(items, strconv.Itoa(i))
+	}
+
+	var running, maxRunning int32
+	results := checkAll(items, func(item string) bool {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		i, _ := strconv.Atoi(item)
+		return i%7 == 3
+	})
+	for i, res := range results {
+		if res != (i%7 == 3) {
+			t.Errorf("wrong result of item %v, got %v", i, res)
+		}
+	}
+	if int(maxRunning) > staleCheckWorkers {
+		t.Errorf("expected up to %v concurrent checks, got %v", staleCheckWorkers, maxRunning)
+	}
+
+	if firstOf(items, func(item string) bool { return item == "42" || item == "77" }) != 42 {
+		t.Error("firstOf failed, expected the first item, in order")
+	}
+	if firstOf(items, func(string) bool { return false }) != -1 {
+		t.Error("firstOf failed, expected -1 when none found")
+	}
+	if len(checkAll([]string{}, func(string) bool { return true })) != 0 {
+		t.Error("checkAll failed, expected no results for no items")
+	}
+}
+
+// A large classpath, of 400 jars and 50 local deps with their manifests,
+// all older than the cp file, so that every check is made.
+func writeLargeClassPath(b *testing.B, dir string) (t4cConfig, []string) {
+	config := t4cConfig{}
+	buildCmdConfigs(&config, path.Join(dir, ".cpcache"), "bench")
+	os.MkdirAll(path.Join(dir, ".cpcache"), os.ModePerm)
+
+	configPath := path.Join(dir, "deps.edn")
+	os.WriteFile(configPath, []byte("{}"), 0644)
+
+	entries := []string{"src"}
+	for i := 0; i < 400; i++ {
+		jar := path.Join(dir, "m2", "lib"+strconv.Itoa(i), "lib-1.0.jar")
+		os.MkdirAll(path.Dir(jar), os.ModePerm)
+		os.WriteFile(jar, []byte{}, 0644)
+		jar, _ = filepath.Abs(jar)
+		entries = append(entries, jar)
+	}
+	manifests := []string{}
+	for i := 0; i < 50; i++ {
+		local, _ := filepath.Abs(path.Join(dir, "local"+strconv.Itoa(i)))
+		os.MkdirAll(path.Join(local, "src"), os.ModePerm)
+		os.WriteFile(path.Join(local, "deps.edn"), []byte("{}"), 0644)
+		entries = append(entries, path.Join(local, "src"))
+		manifests = append(manifests, path.Join(local, "deps.edn"))
+	}
+	os.WriteFile(config.manifestFile, []byte(join(manifests, "\n")), 0644)
+
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(configPath, old, old)
+	for _, manifest := range manifests {
+		os.Chtimes(manifest, old, old)
+	}
+	err := os.WriteFile(config.cpFile, []byte(join(entries, string(os.PathListSeparator))), 0644)
+	if err != nil {
+		b.Fatalf("unable to write file: %v", err)
+	}
+	return config, []string{configPath}
+}
+
+// A copy of the staleness check before stats were memoized, for comparing
+// against; its content mode, not benchmarked, is left out.
+func explainStaleBaseline(options *allOpts, config t4cConfig, configPaths []string, explain func(string)) (bool, error) {
+	flags := []struct {
+		name string
+		set  bool
+	}{
+		{"-Sforce", options.Clj.Force},
+		{"-Strace", options.Clj.Trace},
+		{"-Stree", options.Clj.Tree},
+		{"-P", options.Clj.Prep},
+	}
+	for _, flag := range flags {
+		if flag.set {
+			explain(flag.name + " is set: stale")
+			return true, nil
+		}
+	}
+	explain("none of -Sforce, -Strace, -Stree, -P is set")
+
+	if !fileExists(config.cpFile) {
+		explain("cp file " + config.cpFile + " does not exist: stale")
+		return true, nil
+	}
+	explain("cp file " + config.cpFile + " exists, modified " + modTimeOfBaseline(config.cpFile))
+
+	if len(options.Clj.ToolName) > 0 {
+		configDir, err := getConfigDir()
+		if err != nil {
+			return false, err
+		}
+		toolFile := path.Join(getCljToolsDir(configDir), options.Clj.ToolName+".edn")
+		newer, err := checkIsNewerFileBaseline(toolFile, config.cpFile)
+		if err != nil {
+			return false, err
+		}
+		if newer {
+			explain("tool file " + toolFile + ", modified " + modTimeOfBaseline(toolFile) + ", is newer than the cp file: stale")
+			return true, nil
+		}
+		explain("tool file " + toolFile + ", modified " + modTimeOfBaseline(toolFile) + ", is not newer than the cp file")
+	}
+
+	stale, err := hasMissingEntriesBaseline(config.cpFile, explain)
+	if err != nil || stale {
+		return stale, err
+	}
+
+	for _, path := range configPaths {
+		newer, err := checkIsNewerFileBaseline(path, config.cpFile)
+		if err != nil {
+			return false, err
+		}
+		if newer {
+			explain("config path " + path + ", modified " + modTimeOfBaseline(path) + ", is newer than the cp file: stale")
+			return true, nil
+		}
+		explain("config path " + path + ", modified " + modTimeOfBaseline(path) + ", is not newer than the cp file")
+	}
+
+	if fileExists(config.manifestFile) {
+		manifests, err := readNonEmptyLines(config.manifestFile)
+		if err != nil {
+			return false, err
+		}
+		for _, manifest := range manifests {
+			if !fileExists(manifest) {
+				explain("manifest " + manifest + " does not exist: stale")
+				return true, nil
+			}
+			newer, err := checkIsNewerFileBaseline(manifest, config.cpFile)
+			if err != nil {
+				return false, err
+			}
+			if newer {
+				explain("manifest " + manifest + ", modified " + modTimeOfBaseline(manifest) + ", is newer than the cp file: stale")
+				return true, nil
+			}
+		}
+		explain("none of the " + strconv.Itoa(len(manifests)) + " manifests of " + config.manifestFile + " is newer than the cp file")
+	}
+
+	if fileExists(options.Clj.DepsData) && (options.Clj.DepsData != config.cpFile) {
+		explain("-Sdeps file " + options.Clj.DepsData + " is used: stale")
+		return true, nil
+	}
+
+	explain("classpath is up to date")
+	return false, nil
+}
+
+func hasMissingEntriesBaseline(cpFile string, explain func(string)) (bool, error) {
+	if !fileExists(cpFile) {
+		return false, nil
+	}
+	cp, err := readClassPath(cpFile)
+	if err != nil {
+		return false, err
+	}
+	entries, err := cp.entries()
+	if err != nil {
+		return false, err
+	}
+	missing := []string{}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry, ".jar") {
+			if !fileExists(entry) {
+				missing = append(missing, entry)
+			}
+		} else if filepath.IsAbs(entry) && !dirExists(entry) {
+			missing = append(missing, entry)
+		}
+	}
+	if len(missing) > 0 {
+		explain("classpath entry " + missing[0] + " does not exist: stale")
+		return true, nil
+	}
+	explain("all classpath jars and deps directories exist")
+	return false, nil
+}
+
+func checkIsNewerFileBaseline(file1 string, file2 string) (bool, error) {
+	if !fileExists(file1) {
+		return false, nil
+	}
+	if !fileExists(file2) {
+		return true, nil
+	}
+	sfile1, err := os.Stat(file1)
+	if err != nil {
+		return false, err
+	}
+	sfile2, err := os.Stat(file2)
+	if err != nil {
+		return false, err
+	}
+
+	return sfile1.ModTime().UnixNano() > sfile2.ModTime().UnixNano(), nil
+}
+
+func modTimeOfBaseline(file string) string {
+	info, err := os.Stat(file)
+	if err != nil {
+		return "(missing)"
+	}
+	return info.ModTime().Format("2006-01-02 15:04:05.000")
+}
+
+func BenchmarkExplainStale(b *testing.B) {
+	dir := b.TempDir()
+	config, configPaths := writeLargeClassPath(b, dir)
+	options := allOpts{}
+
+	b.Run("baseline", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			stale, err := explainStaleBaseline(&options, config, configPaths, noExplain)
+			if err != nil || stale {
+				b.Fatalf("expected an up to date classpath, got %v, error %v", stale, err)
+			}
+		}
+	})
+
+	workers := staleCheckWorkers
+	defer func() { staleCheckWorkers = workers }()
+
+	for _, bench := range []struct {
+		name    string
+		workers int
+	}{
+		{"sequential", 1},
+		{"parallel", workers},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			staleCheckWorkers = bench.workers
+			for i := 0; i < b.N; i++ {
+				stale, err := explainStale(&options, config, configPaths, noExplain)
+				if err != nil || stale {
+					b.Fatalf("expected an up to date classpath, got %v, error %v", stale, err)
+				}
+			}
+		})
+	}
+}
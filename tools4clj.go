@@ -17,29 +17,6 @@ import (
 	"os"
 )
 
-func init() {
-	var err error
-	tools4CljDir, err = getTools4CljPath()
-	if err != nil {
-		panic(err)
-	}
-
-	javaPath, err = getJavaPath()
-	if err != nil {
-		panic(err)
-	}
-
-	toolsCp, err = getToolsCp(tools4CljDir)
-	if err != nil {
-		panic(err)
-	}
-
-	execCp, err = getExecCp(tools4CljDir)
-	if err != nil {
-		panic(err)
-	}
-}
-
 // RunClojure runs clojure using the official clojure tools
 func RunClojure(osArgs []string) {
 	runClojure(osArgs, false)
@@ -51,11 +28,31 @@ func RunClj(osArgs []string) {
 }
 
 func runClojure(osArgs []string, cljRun bool) {
-	// download official clojure tools
-	err := getClojureTools(tools4CljDir)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	// read tools4clj settings; a settings error is only reported
+	// after --version and --help, which can do without them
+	var err error
+	settings, settingsSources, err = loadSettings(getSettingsPaths())
+	settingsErr := err
+	if settingsErr != nil {
+		settings, settingsSources = t4cSettings{}, map[string]string{}
+	}
+
+	// use another clojure tools version, set in the
+	// environment, or pinned by the project
+	v := os.Getenv("T4C_TOOLS_VERSION")
+	if v == "" {
+		v, err = projectToolsVersion()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if v != "" {
+		err = setToolsVersion(v)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
 	}
 
 	var opts allOpts
@@ -69,11 +66,115 @@ func runClojure(osArgs []string, cljRun bool) {
 		os.Exit(0)
 	}
 
+	quiet = opts.T4C.Quiet || envTrue("T4C_QUIET")
+
+	for _, k := range ignoredProjectSettings() {
+		t4cPrintln("ignoring " + k + " of " + projectT4CEDN + ", it can only be set in ~/.tools4clj/config.edn")
+	}
+
+	if len(opts.T4C.ToolsVersion) > 0 {
+		err = setToolsVersion(opts.T4C.ToolsVersion)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
 	if opts.Main.Help {
 		fmt.Print(usage + "\n")
 		return
 	}
 
+	if settingsErr != nil {
+		fmt.Fprintln(os.Stderr, settingsErr)
+		os.Exit(1)
+	}
+
+	// list, or remove, the installed clojure tools versions, and exit
+	if opts.T4C.Versions || opts.T4C.GC {
+		t4cDir, err := getT4CHomePath()
+		if err == nil {
+			if opts.T4C.GC {
+				err = gcVersions(t4cDir, opts.T4C.Keep)
+			} else {
+				err = listVersions(t4cDir)
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// check for a newer tools4clj release, and exit
+	if opts.T4C.CheckUpdate {
+		latest, err := latestRelease(releaseFeed(), envDuration("T4C_READ_TIMEOUT", readTimeout))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if compareVersions(latest, version) > 0 {
+			fmt.Println(updateHint(latest))
+			os.Exit(2)
+		}
+		fmt.Println("tools4clj " + version + " is up to date")
+		return
+	}
+
+	// inspect, or clean, the classpath caches, and exit
+	if len(opts.T4C.Cache) > 0 {
+		err = runCacheCommand(opts.T4C.Cache, opts.T4C.OlderThan, opts.Clj.CacheDir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// the rest needs the clojure tools install
+	err = initToolsPaths()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// install clojure tools from a local tarball or directory, and exit
+	if len(opts.T4C.InstallFrom) > 0 {
+		err = getClojureTools(tools4CljDir, opts.T4C.InstallFrom)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// when enabled, hint about a newer tools4clj release once a day
+	t4cDir, err := getT4CHomePath()
+	if err == nil {
+		checkUpdate(t4cDir)
+	}
+
+	// download (or install from T4C_TOOLS_TARBALL) official clojure tools
+	err = getClojureTools(tools4CljDir, os.Getenv("T4C_TOOLS_TARBALL"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// find java, and make sure it can run clojure tools
+	javaPath, err = getProjectJavaPath()
+	if err == nil {
+		javaRuntime, err = detectJava(javaPath)
+	}
+	if err == nil {
+		err = checkJavaVersion(javaRuntime)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	// use command line options
 	err = use(&opts)
 	if err != nil {
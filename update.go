@@ -0,0 +1,151 @@
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// the latest tools4clj release, either as a github release json,
+	// or as plain text holding just its version
+	releaseFeedURL = "https://api.github.com/repos/tasosx/tools4clj/releases/latest"
+	installLatest  = "go install github.com/tasosx/tools4clj/cmd/...@latest"
+
+	updateCheckFile     = "update-check"
+	updateCheckInterval = 24 * time.Hour
+	updateCheckTimeout  = 5 * time.Second
+)
+
+// the update check is opt-in, by T4C_UPDATE_CHECK=1,
+// or {:t4c/update-check true} in the tools4clj config.edn
+func isUpdateCheckEnabled() bool {
+	_, found := os.LookupEnv("T4C_UPDATE_CHECK")
+	if found {
+		return envTrue("T4C_UPDATE_CHECK")
+	}
+	return settings.value(":t4c/update-check") == "true"
+}
+
+func releaseFeed() string {
+	env, found := os.LookupEnv("T4C_UPDATE_URL")
+	if found && env != "" {
+		return env
+	}
+	feed := settings.value(":t4c/update-url")
+	if feed != "" {
+		return feed
+	}
+	return releaseFeedURL
+}
+
+// an update check is due once a day, as recorded in ~/.tools4clj/update-check
+func isUpdateCheckDue(t4cDir string, now time.Time) bool {
+	b, err := os.ReadFile(path.Join(t4cDir, updateCheckFile))
+	if err != nil {
+		return true
+	}
+	last, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return true
+	}
+	return now.Sub(time.Unix(last, 0)) >= updateCheckInterval
+}
+
+// Print a hint when a newer tools4clj release exists, at most once a day.
+// Failures are not reported, a run never fails for an update check.
+func checkUpdate(t4cDir string) {
+	if !isUpdateCheckEnabled() || !isUpdateCheckDue(t4cDir, time.Now()) {
+		return
+	}
+	// recorded before checking, so an unreachable feed is not retried on every run
+	err := os.MkdirAll(t4cDir, os.ModePerm)
+	if err != nil {
+		return
+	}
+	err = os.WriteFile(path.Join(t4cDir, updateCheckFile),
+		[]byte(strconv.FormatInt(time.Now().Unix(), 10)), 0644)
+	if err != nil {
+		return
+	}
+
+	latest, err := latestRelease(releaseFeed(), updateCheckTimeout)
+	if err == nil && compareVersions(latest, version) > 0 {
+		t4cPrintln(updateHint(latest))
+	}
+}
+
+func updateHint(latest string) string {
+	return "tools4clj " + latest + " is available (current " + version + "), update with: " + installLatest
+}
+
+// the version of the latest tools4clj release
+func latestRelease(feedURL string, timeout time.Duration) (string, error) {
+	client, err := httpClient()
+	if err != nil {
+		return "", err
+	}
+	client.Timeout = timeout
+
+	req, err := http.NewRequest(http.MethodGet, feedURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json, text/plain")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New("could not fetch " + feedURL + ": " + resp.Status)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return "", err
+	}
+	return parseRelease(string(b))
+}
+
+// the release version, of a github release json, or a plain text feed
+func parseRelease(text string) (string, error) {
+	release := strings.TrimSpace(text)
+	if strings.HasPrefix(release, "{") {
+		var gh struct {
+			TagName string `json:"tag_name"`
+		}
+		err := json.Unmarshal([]byte(release), &gh)
+		if err != nil {
+			return "", errors.New("invalid release feed: " + err.Error())
+		}
+		release = gh.TagName
+	} else if i := strings.IndexAny(release, " \t\r\n"); i >= 0 {
+		release = release[:i]
+	}
+
+	release = strings.TrimPrefix(release, "v")
+	if !isValidToolsVersion(release) {
+		return "", errors.New("invalid release version in feed: '" + release + "'")
+	}
+	return release, nil
+}
@@ -0,0 +1,154 @@
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseRelease(t *testing.T) {
+	testItems := []struct {
+		text          string
+		expected      string
+		errorExpected bool
+	}{
+		{`{"tag_name": "v1.12.3.1600", "name": "Sixty-seventh release"}`, "1.12.3.1600", false},
+		{`{"tag_name": "1.12.3.1600"}`, "1.12.3.1600", false},
+		{"1.12.3.1600\n", "1.12.3.1600", false},
+		{"v1.12.3.1600 2025-11-01\n", "1.12.3.1600", false},
+		{`{"message": "Not Found"}`, "", true},
+		{`{"tag_name": `, "", true},
+		{"<html></html>", "", true},
+		{"", "", true},
+	}
+
+	for _, v := range testItems {
+		res, err := parseRelease(v.text)
+		if (err != nil) != v.errorExpected {
+			t.Errorf("parse release of %v, expected error %v, got %v", v.text, v.errorExpected, err)
+		}
+		if res != v.expected {
+			t.Errorf("parse release of %v failed, expected %v, got %v", v.text, v.expected, res)
+		}
+	}
+}
+
+func TestIsUpdateCheckDue(t *testing.T) {
+	t4cDir := "test-t4c-home"
+	defer os.RemoveAll(t4cDir)
+
+	now := time.Now()
+	if !isUpdateCheckDue(t4cDir, now) {
+		t.Error("update check not due, when never checked")
+	}
+
+	os.MkdirAll(t4cDir, os.ModePerm)
+	checkFile := path.Join(t4cDir, updateCheckFile)
+
+	os.WriteFile(checkFile, []byte(strconv.FormatInt(now.Add(-time.Hour).Unix(), 10)), 0644)
+	if isUpdateCheckDue(t4cDir, now) {
+		t.Error("update check due, when checked an hour ago")
+	}
+
+	os.WriteFile(checkFile, []byte(strconv.FormatInt(now.Add(-25*time.Hour).Unix(), 10)), 0644)
+	if !isUpdateCheckDue(t4cDir, now) {
+		t.Error("update check not due, when checked a day ago")
+	}
+
+	os.WriteFile(checkFile, []byte("garbage"), 0644)
+	if !isUpdateCheckDue(t4cDir, now) {
+		t.Error("update check not due, when the last check is unknown")
+	}
+}
+
+func TestLatestRelease(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/latest", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"tag_name": "v1.12.3.1600"}`))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+		w.Write([]byte("1.12.3.1600"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	res, err := latestRelease(server.URL+"/latest", time.Second)
+	if err != nil || res != "1.12.3.1600" {
+		t.Errorf("wrong latest release, expected %v, got %v, error %v", "1.12.3.1600", res, err)
+	}
+
+	_, err = latestRelease(server.URL+"/missing", time.Second)
+	if err == nil {
+		t.Error("expected to get an error for not found release feed")
+	}
+
+	_, err = latestRelease(server.URL+"/slow", 100*time.Millisecond)
+	if err == nil {
+		t.Error("expected to get an error for timed out release feed")
+	}
+}
+
+func TestCheckUpdate(t *testing.T) {
+	for _, name := range []string{"T4C_UPDATE_CHECK", "T4C_UPDATE_URL"} {
+		env, found := os.LookupEnv(name)
+		if found {
+			defer os.Setenv(name, env)
+		} else {
+			defer os.Unsetenv(name)
+		}
+	}
+	defer func() { settings = t4cSettings{} }()
+
+	t4cDir := "test-t4c-home"
+	defer os.RemoveAll(t4cDir)
+
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte("99.0.0.1"))
+	}))
+	defer server.Close()
+	os.Setenv("T4C_UPDATE_URL", server.URL)
+
+	// disabled by default
+	os.Unsetenv("T4C_UPDATE_CHECK")
+	settings = t4cSettings{}
+	checkUpdate(t4cDir)
+	if hits != 0 {
+		t.Error("update checked, while not enabled")
+	}
+
+	// enabled in settings, checked once a day
+	settings = t4cSettings{":t4c/update-check": []string{"true"}}
+	checkUpdate(t4cDir)
+	checkUpdate(t4cDir)
+	if hits != 1 {
+		t.Errorf("update checked %v times, expected once", hits)
+	}
+
+	// disabled by the environment, overriding settings
+	os.RemoveAll(t4cDir)
+	os.Setenv("T4C_UPDATE_CHECK", "0")
+	checkUpdate(t4cDir)
+	if hits != 1 {
+		t.Error("update checked, while disabled")
+	}
+}
@@ -0,0 +1,212 @@
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// a clojure tools version, installed in ~/.tools4clj
+type installedVersion struct {
+	version  string
+	size     int64
+	complete bool
+}
+
+// the installed versions in t4cDir, newest first
+func installedVersions(t4cDir string) ([]installedVersion, error) {
+	entries, err := os.ReadDir(t4cDir)
+	if os.IsNotExist(err) {
+		return []installedVersion{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []installedVersion{}
+	for _, entry := range entries {
+		if !entry.IsDir() || !isValidToolsVersion(entry.Name()) {
+			continue
+		}
+		dir := path.Join(t4cDir, entry.Name())
+		versions = append(versions, installedVersion{
+			version:  entry.Name(),
+			size:     dirSize(dir),
+			complete: fileExists(path.Join(dir, toolsInstalled)),
+		})
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return compareVersions(versions[i].version, versions[j].version) > 0
+	})
+	return versions, nil
+}
+
+// compare dot separated numeric versions, returning -1, 0 or 1
+func compareVersions(v1 string, v2 string) int {
+	parts1 := strings.Split(v1, ".")
+	parts2 := strings.Split(v2, ".")
+	for i := 0; i < len(parts1) || i < len(parts2); i++ {
+		n1, n2 := 0, 0
+		if i < len(parts1) {
+			n1, _ = strconv.Atoi(parts1[i])
+		}
+		if i < len(parts2) {
+			n2, _ = strconv.Atoi(parts2[i])
+		}
+		if n1 != n2 {
+			if n1 < n2 {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err == nil && !d.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// print the installed versions, marking the active one with *
+func listVersions(t4cDir string) error {
+	versions, err := installedVersions(t4cDir)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		fmt.Println("no clojure tools versions installed in " + t4cDir)
+		return nil
+	}
+
+	for _, v := range versions {
+		mark := " "
+		if v.version == toolsVersion {
+			mark = "*"
+		}
+		line := fmt.Sprintf("%s %-14s %10s", mark, v.version, formatBytes(v.size))
+		if !v.complete {
+			line += "  (partial)"
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// Remove the installed versions, except the keep newest ones, the active
+// one, and the default one of this tools4clj. A version being installed,
+// by another process holding its install lock, is left untouched.
+func gcVersions(t4cDir string, keep int) error {
+	versions, err := installedVersions(t4cDir)
+	if err != nil {
+		return err
+	}
+
+	kept := 0
+	for _, v := range versions {
+		if v.version == toolsVersion || v.version == version {
+			continue
+		}
+		if v.complete && kept < keep {
+			kept++
+			continue
+		}
+
+		removed, err := removeVersion(t4cDir, v.version)
+		if err != nil {
+			return err
+		}
+		if removed {
+			fmt.Println("removed clojure tools " + v.version + ", freed " + formatBytes(v.size))
+		} else {
+			fmt.Println("skipped clojure tools " + v.version + ", in use by another process")
+		}
+	}
+
+	// leftovers of interrupted installs of versions no longer installed
+	leftovers, err := filepath.Glob(path.Join(t4cDir, "*"))
+	if err != nil {
+		return err
+	}
+	for _, leftover := range leftovers {
+		v := leftoverVersion(path.Base(leftover))
+		if !isValidToolsVersion(v) || dirExists(path.Join(t4cDir, v)) {
+			continue
+		}
+		_, err := removeVersion(t4cDir, v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the version of an install staging directory, or a partial tarball download
+func leftoverVersion(name string) string {
+	if strings.HasPrefix(name, ".") && strings.Contains(name, stageSuffix) {
+		return strings.TrimPrefix(name[:strings.Index(name, stageSuffix)], ".")
+	}
+	if strings.HasPrefix(name, "clojure-tools-") && strings.HasSuffix(name, ".tar.gz.part") {
+		return strings.TrimSuffix(strings.TrimPrefix(name, "clojure-tools-"), ".tar.gz.part")
+	}
+	return ""
+}
+
+// remove a version, along with its install leftovers and lock file,
+// when not locked
+func removeVersion(t4cDir string, v string) (bool, error) {
+	toolsDir := path.Join(t4cDir, v)
+	lock, err := tryAcquireLock(toolsLockPath(toolsDir))
+	if err != nil {
+		return false, err
+	}
+	if lock == nil {
+		return false, nil
+	}
+
+	leftovers, err := filepath.Glob(path.Join(t4cDir, "."+v+stageSuffix+"*"))
+	if err != nil {
+		return false, err
+	}
+	leftovers = append(leftovers,
+		path.Join(t4cDir, "clojure-tools-"+v+".tar.gz"),
+		path.Join(t4cDir, "clojure-tools-"+v+".tar.gz.part"),
+		toolsDir)
+	for _, leftover := range leftovers {
+		err = os.RemoveAll(leftover)
+		if err != nil {
+			lock.release()
+			return false, err
+		}
+	}
+	// no lock file is left, of the removed version
+	return true, lock.remove()
+}
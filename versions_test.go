@@ -0,0 +1,166 @@
+/*************************************************************************
+ * Copyright (c) 2019 Tasos Mamaloukos.
+ *
+ * All rights reserved. This program and the accompanying materials
+ * are made available under the terms of the Eclipse Public License v1.0
+ * which accompanies this distribution.
+ *
+ * The Eclipse Public License is available at
+ *     https://www.eclipse.org/org/documents/epl-v10.html
+ *
+ *************************************************************************/
+
+package tools4clj
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+// a tools4clj home with complete installs of the given versions
+func writeT4CHome(t4cDir string, versions []string) error {
+	for _, v := range versions {
+		toolsDir := path.Join(t4cDir, v)
+		err := writeToolsDir(toolsDir)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(path.Join(toolsDir, toolsInstalled), []byte{}, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestInstalledVersions(t *testing.T) {
+	t4cDir := "test-t4c-home"
+	defer os.RemoveAll(t4cDir)
+
+	res, err := installedVersions(t4cDir)
+	if err != nil || len(res) != 0 {
+		t.Errorf("expected no versions for not existing dir, got %v, error %v", res, err)
+	}
+
+	err = writeT4CHome(t4cDir, []string{"1.10.3.986", "1.12.0.1479", "1.9.0.397"})
+	if err != nil {
+		t.Errorf("unable to write tools4clj home: %v", err)
+		t.FailNow()
+	}
+	// a partial install, and other files
+	os.MkdirAll(path.Join(t4cDir, "1.11.1.1429"), os.ModePerm)
+	os.WriteFile(path.Join(t4cDir, t4cConfigEDN), []byte("{}"), 0644)
+
+	res, err = installedVersions(t4cDir)
+	if err != nil {
+		t.Errorf("failed to get installed versions: %v", err)
+	}
+	expected := []string{"1.12.0.1479", "1.11.1.1429", "1.10.3.986", "1.9.0.397"}
+	if len(res) != len(expected) {
+		t.Errorf("wrong installed versions, expected %v, got %v", expected, res)
+		t.FailNow()
+	}
+	for i, v := range res {
+		if v.version != expected[i] {
+			t.Errorf("wrong installed version, expected %v, got %v", expected[i], v.version)
+		}
+		if v.complete != (v.version != "1.11.1.1429") {
+			t.Errorf("wrong complete state of version %v", v.version)
+		}
+		if v.complete && v.size == 0 {
+			t.Errorf("wrong size of version %v", v.version)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	testItems := []struct {
+		v1       string
+		v2       string
+		expected int
+	}{
+		{"1.12.3.1577", "1.12.3.1577", 0},
+		{"1.12.3.1577", "1.12.0.1479", 1},
+		{"1.9.0.397", "1.10.3.986", -1},
+		{"1.12", "1.12.0.0", 0},
+	}
+
+	for _, v := range testItems {
+		res := compareVersions(v.v1, v.v2)
+		if res != v.expected {
+			t.Errorf("compareVersions of %v and %v failed, expected %v, got %v", v.v1, v.v2, v.expected, res)
+		}
+	}
+}
+
+func TestLeftoverVersion(t *testing.T) {
+	testItems := map[string]string{
+		".1.12.3.1577" + stageSuffix + "123456":   "1.12.3.1577",
+		"clojure-tools-1.12.3.1577.tar.gz.part":   "1.12.3.1577",
+		"clojure-tools-1.12.3.1577.tar.gz.sha256": "",
+		"1.12.3.1577": "",
+		"config.edn":  "",
+	}
+
+	for name, expected := range testItems {
+		res := leftoverVersion(name)
+		if res != expected {
+			t.Errorf("leftoverVersion of %v failed, expected %v, got %v", name, expected, res)
+		}
+	}
+}
+
+func TestGCVersions(t *testing.T) {
+	defer setToolsVersion(version)
+
+	t4cDir := "test-t4c-home"
+	defer os.RemoveAll(t4cDir)
+
+	err := writeT4CHome(t4cDir, []string{version, "1.9.0.397", "1.10.3.986", "1.11.1.1429", "1.12.0.1479"})
+	if err != nil {
+		t.Errorf("unable to write tools4clj home: %v", err)
+		t.FailNow()
+	}
+	// leftovers of interrupted installs
+	os.MkdirAll(path.Join(t4cDir, ".1.8.0.193"+stageSuffix+"123"), os.ModePerm)
+	os.WriteFile(path.Join(t4cDir, "clojure-tools-1.8.0.193.tar.gz.part"), []byte("part"), 0644)
+
+	// the active version, and one being installed by another process
+	toolsVersion = "1.9.0.397"
+	lock, err := acquireLock(toolsLockPath(path.Join(t4cDir, "1.10.3.986")), 0, "")
+	if err != nil {
+		t.Errorf("failed to acquire install lock: %v", err)
+		t.FailNow()
+	}
+	defer lock.release()
+
+	err = gcVersions(t4cDir, 1)
+	if err != nil {
+		t.Errorf("failed to gc versions: %v", err)
+	}
+
+	for v, expected := range map[string]bool{
+		version:       true, // default
+		"1.12.0.1479": true, // kept newest
+		"1.11.1.1429": false,
+		"1.10.3.986":  true, // locked
+		"1.9.0.397":   true, // active
+	} {
+		if dirExists(path.Join(t4cDir, v)) != expected {
+			t.Errorf("gc of version %v failed, expected to exist %v", v, expected)
+		}
+	}
+	if dirExists(path.Join(t4cDir, ".1.8.0.193"+stageSuffix+"123")) ||
+		fileExists(path.Join(t4cDir, "clojure-tools-1.8.0.193.tar.gz.part")) {
+		t.Error("gc of install leftovers failed")
+	}
+	for _, v := range []string{"1.11.1.1429", "1.8.0.193"} {
+		if fileExists(toolsLockPath(path.Join(t4cDir, v))) {
+			t.Errorf("gc of version %v left its lock file", v)
+		}
+	}
+	if !fileExists(toolsLockPath(path.Join(t4cDir, "1.10.3.986"))) {
+		t.Error("gc removed the lock file of a locked version")
+	}
+}